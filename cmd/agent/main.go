@@ -2,16 +2,27 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/azguard/azguard/internal/cloud/azure"
 	"github.com/azguard/azguard/internal/config"
 	"github.com/azguard/azguard/internal/cost"
+	"github.com/azguard/azguard/internal/notify"
 	"github.com/azguard/azguard/internal/storage"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 // Version information (set by goreleaser at build time)
@@ -26,9 +37,19 @@ var (
 	db           *storage.DB
 	costSvc      *cost.Service
 	outputFormat string
+
+	freeTierConfigPath string
+	configPath         string
+	logLevel           string
+	logFormat          string
+	verbose            bool
+	quiet              bool
+
+	azureTokenProvider azure.TokenProvider
 )
 
-func main() {
+// newRootCmd builds the azguard command tree.
+func newRootCmd() *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "azguard",
 		Short: "azguard - Protect against Azure free tier bill shock",
@@ -40,8 +61,24 @@ Examples:
   azguard budget add 5     Add a $5 budget alert
   azguard watch            Monitor costs daily`,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			var err error
-			cfg, err = config.Load("")
+			// --verbose and --quiet pick a log level unless one was given
+			if !cmd.Flags().Changed("log-level") {
+				if verbose {
+					logLevel = "debug"
+				} else if quiet {
+					logLevel = "error"
+				}
+			}
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
+			logger, err := newLogger(logLevel, logFormat, os.Stderr)
+			if err != nil {
+				return err
+			}
+			slog.SetDefault(logger)
+
+			cfg, err = config.Load(configPath)
 			if err != nil {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
@@ -51,7 +88,22 @@ Examples:
 				return fmt.Errorf("failed to initialize database: %w", err)
 			}
 
-			tokenProvider, err := azure.NewTokenProvider(cfg.Azure.AuthMethod, map[string]string{
+			overrides, err := db.GetAllConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load config overrides: %w", err)
+			}
+			cfg.ApplyOverrides(overrides)
+
+			// config and doctor must keep working so a bad setting can be
+			// inspected and fixed
+			if err := cfg.Validate(); err != nil {
+				if !allowsInvalidConfig(cmd) {
+					return fmt.Errorf("invalid configuration:\n%w", err)
+				}
+				slog.Warn("invalid configuration", "err", err)
+			}
+
+			azureTokenProvider, err = azure.NewTokenProvider(cfg.Azure.AuthMethod, map[string]string{
 				"tenant_id":     cfg.Azure.TenantID,
 				"client_id":     cfg.Azure.ClientID,
 				"client_secret": cfg.Azure.ClientSecret,
@@ -60,8 +112,47 @@ Examples:
 				return fmt.Errorf("failed to create token provider: %w", err)
 			}
 
-			azureCostClient := azure.NewCostClient(cfg.Azure.SubscriptionID, tokenProvider)
-			costSvc = cost.NewService(db, azureCostClient)
+			if freeTierConfigPath != "" {
+				cfg.FreeTierPath = freeTierConfigPath
+			}
+
+			azureCostClient := azure.NewCostClient(cfg.Azure.SubscriptionID, azureTokenProvider)
+			costSvc = cost.NewService(db, cost.NewAzureProvider(azureCostClient))
+			for _, subID := range cfg.Azure.Subscriptions {
+				if subID != "" && subID != cfg.Azure.SubscriptionID {
+					costSvc.AddProvider(cost.NewAzureProvider(azure.NewCostClient(subID, azureTokenProvider)))
+				}
+			}
+			costSvc.SetFreeTierPath(cfg.FreeTierPath)
+			costSvc.SetBillingCycleStartDay(cfg.BillingCycleStartDay)
+			costSvc.SetCacheTTL(cfg.Cache.TTL)
+			costSvc.SetCircuitBreaker(cfg.CircuitBreaker.FailureThreshold, cfg.CircuitBreaker.Cooldown)
+			if verbose {
+				costSvc.SetProgress(func(step string) {
+					fmt.Fprintf(os.Stderr, "→ %s\n", step)
+				})
+			}
+			var notifiers []notify.Notifier
+			if cfg.Alerts.WebhookURL != "" {
+				notifiers = append(notifiers, notify.NewWebhookNotifier(cfg.Alerts.WebhookURL))
+			}
+			if cfg.Alerts.SlackWebhook != "" {
+				notifiers = append(notifiers, notify.NewSlackNotifier(cfg.Alerts.SlackWebhook))
+			}
+			if len(notifiers) > 0 {
+				costSvc.SetNotifier(notify.Multi(notifiers...))
+			}
+			if cfg.Email.SMTPHost != "" && cfg.Email.From != "" {
+				costSvc.SetEmailSender(&notify.SMTPSender{
+					Host:     cfg.Email.SMTPHost,
+					Port:     cfg.Email.SMTPPort,
+					Username: cfg.Email.Username,
+					Password: cfg.Email.Password,
+					From:     cfg.Email.From,
+					TLS:      cfg.Email.TLS,
+				})
+			}
+			configureCurrency(costSvc, cfg)
 
 			return nil
 		},
@@ -73,7 +164,14 @@ Examples:
 		},
 	}
 
-	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format: table, json, csv")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format: "+strings.Join(outputFormats, ", "))
+	rootCmd.PersistentFlags().StringVar(&freeTierConfigPath, "free-tier-config", "", "Path to a free tier limits YAML file")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to a config file (default ~/.azguard/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn, error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format: text, json")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Show progress and debug logs")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print results and errors")
+	rootCmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
 
 	// Add version flag
 	var showVersion bool
@@ -97,13 +195,119 @@ Examples:
 	rootCmd.AddCommand(statusCmd())
 	rootCmd.AddCommand(configCmd())
 	rootCmd.AddCommand(costCmd())
+	rootCmd.AddCommand(doctorCmd())
+
+	return rootCmd
+}
 
-	if err := rootCmd.Execute(); err != nil {
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
+// newLogger builds the logger for diagnostics. Logs go to w (stderr) so they
+// never mix with command output on stdout. Text logs omit the timestamp to
+// stay readable in a terminal.
+func newLogger(level, format string, w io.Writer) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q (use debug, info, warn, or error)", level)
+	}
+
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl})), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+			Level: lvl,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey && len(groups) == 0 {
+					return slog.Attr{}
+				}
+				return a
+			},
+		})), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (use text or json)", format)
+	}
+}
+
+// outputFormats are the accepted --output values. Views without a rendering
+// for csv, markdown or yaml print their table instead.
+var outputFormats = []string{"table", "json", "csv", "markdown", "yaml"}
+
+func validateOutputFormat(format string) error {
+	for _, f := range outputFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid output format %q (use %s)", format, strings.Join(outputFormats, ", "))
+}
+
+// printYAML prints v as YAML with the same field names as its JSON output.
+func printYAML(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// JSON is valid YAML; decoding it into a node keeps the key order
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return err
+	}
+	clearYAMLStyle(&node)
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// clearYAMLStyle drops the flow and quoting styles carried over from JSON so
+// the output is block-style YAML. Strings that need quoting are still quoted.
+func clearYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearYAMLStyle(child)
+	}
+}
+
+// statusf prints a confirmation that isn't the command's result, such as
+// "Costs fetched and stored". --quiet suppresses it.
+func statusf(format string, args ...interface{}) {
+	if !quiet {
+		fmt.Printf(format, args...)
+	}
+}
+
+// allowsInvalidConfig reports whether cmd is, or is under, a command that
+// runs even when the configuration fails validation.
+// configureCurrency applies the configured target currency, which budgets
+// are expressed in, even when no rates are set. Without rates there is no
+// converter, so records in several currencies are reported rather than summed.
+func configureCurrency(svc *cost.Service, c *config.Config) {
+	var converter cost.CurrencyConverter
+	if len(c.Currency.Rates) > 0 {
+		converter = cost.NewStaticRateConverter(c.Currency.Target, c.Currency.Rates)
+	}
+	svc.SetCurrencyConverter(converter, c.Currency.Target)
+}
+
+func allowsInvalidConfig(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Name() == "config" || c.Name() == "doctor" {
+			return true
+		}
+	}
+	return false
+}
+
 func versionCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "version",
@@ -126,6 +330,9 @@ func statusCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if summary.Warning != "" {
+				slog.Warn(summary.Warning)
+			}
 
 			// Calculate free tier status
 			limit := 200.0 // Approximate monthly free tier value in USD
@@ -134,7 +341,7 @@ func statusCmd() *cobra.Command {
 			fmt.Println("\n🛡️  Azure Free Tier Status")
 			fmt.Println("═══════════════════════════════")
 			fmt.Printf("Subscription: %s\n", cfg.Azure.SubscriptionID)
-			fmt.Printf("Current Spend: $%.2f / $%.2f free\n", summary.TotalCost, limit)
+			fmt.Printf("Current Spend: %s / %s free\n", cost.FormatMoney(summary.TotalCost, summary.Currency), cost.FormatMoney(limit, "USD"))
 
 			if percentUsed >= 100 {
 				fmt.Println("⚠️  Status: OVER LIMIT")
@@ -150,11 +357,14 @@ func statusCmd() *cobra.Command {
 				fmt.Printf("\n🔔 Active Alerts: %d\n", len(alerts))
 				for _, a := range alerts {
 					if a.Enabled {
-						triggered := ""
-						if summary.TotalCost >= a.Threshold {
-							triggered = " (TRIGGERED)"
+						marker := ""
+						switch cost.EvaluateAlert(a, cost.AlertSpend(a, summary)) {
+						case cost.AlertTriggered:
+							marker = " (TRIGGERED)"
+						case cost.AlertWarning:
+							marker = " (WARNING)"
 						}
-						fmt.Printf("  • %s: $%.2f%s\n", a.Name, a.Threshold, triggered)
+						fmt.Printf("  • %s: %s%s\n", a.Name, cost.FormatMoney(a.Threshold, summary.Currency), marker)
 					}
 				}
 			}
@@ -165,6 +375,79 @@ func statusCmd() *cobra.Command {
 	}
 }
 
+// doctorCheck is the outcome of one doctor check.
+type doctorCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func doctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that cloud credentials and configuration work",
+		Long: `Acquire an Azure access token with the configured auth method and validate
+the configured subscriptions, without querying any costs.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var checks []doctorCheck
+			record := func(name string, err error) {
+				c := doctorCheck{Name: name, OK: err == nil}
+				if err != nil {
+					c.Error = err.Error()
+				}
+				checks = append(checks, c)
+			}
+
+			record("configuration", cfg.Validate())
+
+			_, err := azureTokenProvider()
+			record(fmt.Sprintf("azure credentials (%s)", cfg.Azure.AuthMethod), err)
+			// Each subscription is probed once. The primary is checked even
+			// when empty since it's required; blank extras are ignored, as
+			// they are when fetching
+			seen := map[string]bool{}
+			for i, subID := range append([]string{cfg.Azure.SubscriptionID}, cfg.Azure.Subscriptions...) {
+				if seen[subID] || (i > 0 && subID == "") {
+					continue
+				}
+				seen[subID] = true
+				record(strings.TrimSpace("azure subscription "+subID), azure.ValidateSubscriptionID(subID))
+			}
+
+			failed := 0
+			for _, c := range checks {
+				if !c.OK {
+					failed++
+				}
+			}
+
+			if outputFormat == "json" {
+				b, err := json.MarshalIndent(checks, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+			} else {
+				fmt.Println("\n🩺 azguard doctor")
+				fmt.Println("─────────────────────────────")
+				for _, c := range checks {
+					if c.OK {
+						fmt.Printf("✅ %s\n", c.Name)
+					} else {
+						fmt.Printf("❌ %s: %s\n", c.Name, c.Error)
+					}
+				}
+			}
+
+			if failed > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+			}
+			return nil
+		},
+	}
+}
+
 func scanCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "scan",
@@ -185,7 +468,7 @@ Shows which services are approaching or exceeding their free allocations.`,
 				return err
 			}
 
-			_, err = cost.LoadFreeTierConfig()
+			_, err = cost.LoadFreeTierConfig(cfg.FreeTierPath)
 			if err != nil {
 				return err
 			}
@@ -202,7 +485,7 @@ Shows which services are approaching or exceeding their free allocations.`,
 			limit := 200.0 // Approximate monthly free tier value
 			percentUsed := (summary.TotalCost / limit) * 100
 
-			fmt.Printf("\nTotal Spend: $%.2f / $%.2f free tier\n", summary.TotalCost, limit)
+			fmt.Printf("\nTotal Spend: %s / %s free tier\n", cost.FormatMoney(summary.TotalCost, summary.Currency), cost.FormatMoney(limit, "USD"))
 			fmt.Printf("Usage: %.1f%%\n\n", percentUsed)
 
 			fmt.Println("By Service:")
@@ -238,7 +521,7 @@ Shows which services are approaching or exceeding their free allocations.`,
 					}
 				}
 
-				fmt.Printf("%s %-20s $%.2f\n", status, service+":", c)
+				fmt.Printf("%s %-20s %s\n", status, service+":", cost.FormatMoney(c, summary.Currency))
 			}
 
 			if !issuesFound {
@@ -277,36 +560,8 @@ func budgetCmd() *cobra.Command {
 		Long:  `Set up budget alerts to get notified before unexpected charges.`,
 	}
 
-	cmd.AddCommand(&cobra.Command{
-		Use:   "add [amount]",
-		Short: "Add a budget alert",
-		Args:  cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			var amount float64
-			if _, err := fmt.Sscanf(args[0], "%f", &amount); err != nil {
-				return fmt.Errorf("invalid amount: %w", err)
-			}
-
-			// Validate amount
-			if amount < 1 || amount > 100 {
-				return fmt.Errorf("budget amount should be between $1 and $100")
-			}
-
-			alert := storage.Alert{
-				Name:      fmt.Sprintf("budget-%.0f", amount),
-				Threshold: amount,
-				Enabled:   true,
-			}
-
-			if err := db.SaveAlert(alert); err != nil {
-				return err
-			}
-
-			fmt.Printf("✅ Budget alert set: $%.2f\n", amount)
-			fmt.Println("   You'll be notified when costs exceed this amount.")
-			return nil
-		},
-	})
+	cmd.AddCommand(budgetAddCmd())
+	cmd.AddCommand(budgetCheckCmd())
 
 	cmd.AddCommand(&cobra.Command{
 		Use:   "list",
@@ -330,7 +585,7 @@ func budgetCmd() *cobra.Command {
 				if !a.Enabled {
 					status = "❌ Disabled"
 				}
-				fmt.Printf("$%.2f - %s\n", a.Threshold, status)
+				fmt.Printf("%-24s %s - %s%s\n", a.Name, cost.FormatMoney(a.Threshold, costSvc.TargetCurrency()), status, alertScope(a))
 			}
 			return nil
 		},
@@ -350,11 +605,59 @@ func budgetCmd() *cobra.Command {
 		},
 	})
 
+	cmd.AddCommand(&cobra.Command{
+		Use:   "enable [name]",
+		Short: "Enable a budget alert",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := db.SetAlertEnabled(args[0], true); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Alert '%s' enabled\n", args[0])
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "disable [name]",
+		Short: "Disable a budget alert without removing it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := db.SetAlertEnabled(args[0], false); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Alert '%s' disabled\n", args[0])
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "update [name] [amount]",
+		Short: "Change a budget alert's threshold",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var amount float64
+			if _, err := fmt.Sscanf(args[1], "%f", &amount); err != nil {
+				return fmt.Errorf("invalid amount: %w", err)
+			}
+
+			if amount < 1 || amount > 100 {
+				return fmt.Errorf("budget amount should be between $1 and $100")
+			}
+
+			if err := db.UpdateAlertThreshold(args[0], amount); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Alert '%s' threshold set to %s\n", args[0], cost.FormatMoney(amount, costSvc.TargetCurrency()))
+			return nil
+		},
+	})
+
 	cmd.AddCommand(&cobra.Command{
 		Use:   "presets",
 		Short: "Show preset budget options",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			config, err := cost.LoadFreeTierConfig()
+			config, err := cost.LoadFreeTierConfig(cfg.FreeTierPath)
 			if err != nil {
 				return err
 			}
@@ -362,7 +665,7 @@ func budgetCmd() *cobra.Command {
 			fmt.Println("\n💰 Budget Presets")
 			fmt.Println("─────────────────────────────")
 			for _, preset := range config.Budgets {
-				fmt.Printf("  $%-2.0f  %s\n", preset.Amount, preset.Description)
+				fmt.Printf("  %-6s  %s\n", cost.FormatMoney(preset.Amount, costSvc.TargetCurrency()), preset.Description)
 				fmt.Printf("         Run: azguard budget add %.0f\n\n", preset.Amount)
 			}
 			return nil
@@ -372,6 +675,136 @@ func budgetCmd() *cobra.Command {
 	return cmd
 }
 
+func budgetAddCmd() *cobra.Command {
+	var warning float64
+	var service, resourceGroup string
+	cmd := &cobra.Command{
+		Use:   "add [amount]",
+		Short: "Add a budget alert",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var amount float64
+			if _, err := fmt.Sscanf(args[0], "%f", &amount); err != nil {
+				return fmt.Errorf("invalid amount: %w", err)
+			}
+
+			// Validate amount
+			if amount < 1 || amount > 100 {
+				return fmt.Errorf("budget amount should be between $1 and $100")
+			}
+			if warning <= 0 || warning > 1 {
+				return fmt.Errorf("warning threshold should be a fraction between 0 and 1")
+			}
+			if service != "" && resourceGroup != "" {
+				return fmt.Errorf("a budget alert can be scoped to a service or a resource group, not both")
+			}
+
+			name := "budget-" + strconv.FormatFloat(amount, 'f', -1, 64)
+			if service != "" {
+				name += "-" + service
+			} else if resourceGroup != "" {
+				name += "-" + resourceGroup
+			}
+			alert := storage.Alert{
+				Name:             name,
+				Threshold:        amount,
+				Enabled:          true,
+				WarningThreshold: warning,
+				ServiceName:      service,
+				ResourceGroup:    resourceGroup,
+			}
+
+			if err := db.SaveAlert(alert); err != nil {
+				return err
+			}
+
+			fmt.Printf("✅ Budget alert set: %s%s\n", cost.FormatMoney(amount, costSvc.TargetCurrency()), alertScope(alert))
+			fmt.Println("   You'll be notified when costs exceed this amount.")
+			return nil
+		},
+	}
+
+	cmd.Flags().Float64Var(&warning, "warning", storage.DefaultWarningThreshold, "Warn when spend reaches this fraction of the budget")
+	cmd.Flags().StringVar(&service, "service", "", "Only count spend from this service (e.g. VirtualMachines)")
+	cmd.Flags().StringVar(&resourceGroup, "resource-group", "", "Only count spend from this resource group")
+
+	return cmd
+}
+
+// alertScope describes what spend a scoped alert watches, for display.
+func alertScope(a storage.Alert) string {
+	switch {
+	case a.ServiceName != "":
+		return " (service: " + a.ServiceName + ")"
+	case a.ResourceGroup != "":
+		return " (resource group: " + a.ResourceGroup + ")"
+	default:
+		return ""
+	}
+}
+
+func budgetCheckCmd() *cobra.Command {
+	var failOnTrigger bool
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check current spend against budget alerts",
+		Long: `Check current spend against budget alerts. When alerts.webhook_url is set,
+each alert is notified once when it first crosses its threshold. The same
+applies to alerts.slack_webhook.
+
+With --fail-on-trigger the command exits non-zero when any alert is
+triggered, so it can gate CI pipelines or cron jobs.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			summary, err := costSvc.GetCurrentCosts(ctx)
+			if err != nil {
+				return err
+			}
+			if summary.Warning != "" {
+				slog.Warn(summary.Warning)
+			}
+
+			results, err := costSvc.CheckAlerts(ctx, summary)
+			if err != nil {
+				return err
+			}
+
+			if len(results) == 0 {
+				fmt.Println("No budget alerts configured.")
+				return nil
+			}
+
+			fmt.Printf("\n🔔 Budget Check - current spend %s\n", cost.FormatMoney(summary.TotalCost, summary.Currency))
+			fmt.Println("─────────────────────────────")
+			triggered := 0
+			for _, r := range results {
+				status := "✅ OK"
+				switch r.State {
+				case cost.AlertTriggered:
+					status = "❌ TRIGGERED"
+					triggered++
+				case cost.AlertWarning:
+					status = "⚠️  WARNING"
+				}
+				fmt.Printf("%-12s %s: %s / %s%s\n", status, r.Alert.Name, cost.FormatMoney(r.Spend, summary.Currency), cost.FormatMoney(r.Alert.Threshold, summary.Currency), alertScope(r.Alert))
+				if r.NotifyErr != nil {
+					slog.Warn("failed to send alert notification", "alert", r.Alert.Name, "err", r.NotifyErr)
+				}
+			}
+
+			if failOnTrigger && triggered > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d of %d budget alerts triggered", triggered, len(results))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&failOnTrigger, "fail-on-trigger", false, "Exit non-zero if any alert is triggered")
+	return cmd
+}
+
 func resourcesCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "resources",
@@ -427,98 +860,1397 @@ func configCmd() *cobra.Command {
 	cmd.AddCommand(&cobra.Command{
 		Use:   "list",
 		Short: "Show current configuration",
+		Long:  `Show the effective configuration. Values marked * are overrides saved with 'azguard config set'.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			overrides, err := db.GetAllConfig()
+			if err != nil {
+				return err
+			}
+
+			settings := cfg.Settings()
+			if outputFormat == "json" {
+				settings["storage.path"] = cfg.Storage.Path
+				b, err := json.MarshalIndent(settings, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+
 			fmt.Println("\n⚙️  azguard Configuration")
 			fmt.Println("═══════════════════════════════")
-			fmt.Printf("Azure Subscription: %s\n", cfg.Azure.SubscriptionID)
-			fmt.Printf("Auth Method: %s\n", cfg.Azure.AuthMethod)
-			fmt.Printf("Storage Path: %s\n", cfg.Storage.Path)
+			for _, key := range config.Keys() {
+				marker := " "
+				if _, ok := overrides[key]; ok {
+					marker = "*"
+				}
+				fmt.Printf("%s %-22s %s\n", marker, key+":", settings[key])
+			}
+			fmt.Printf("  %-22s %s\n", "storage.path:", cfg.Storage.Path)
 			fmt.Println()
 			return nil
 		},
 	})
 
 	cmd.AddCommand(&cobra.Command{
-		Use:   "set [key] [value]",
-		Short: "Set configuration value",
-		Args:  cobra.ExactArgs(2),
+		Use:   "get [key]",
+		Short: "Show a configuration value",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			key := "azure." + args[0]
-			if args[0] == "subscription" {
-				key = "azure.subscription_id"
+			key, err := config.CanonicalKey(args[0])
+			if err != nil {
+				return err
 			}
-			if args[0] == "auth" {
-				key = "azure.auth_method"
+			// cfg already has stored overrides applied
+			value, err := cfg.Value(key)
+			if err != nil {
+				return err
 			}
-			return db.SetConfig(key, args[1])
+			fmt.Println(value)
+			return nil
 		},
 	})
 
-	return cmd
-}
-
-func costCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "cost",
-		Short: "Advanced cost management",
-	}
-
 	cmd.AddCommand(&cobra.Command{
-		Use:   "current",
-		Short: "Show current month costs",
+		Use:   "set [key] [value]",
+		Short: "Set configuration value",
+		Long: `Save a configuration override. Overrides take precedence over the config
+file and defaults. Run 'azguard config list' to see the valid keys.`,
+		Args: cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := context.Background()
-			summary, err := costSvc.GetCurrentCosts(ctx)
+			key, err := config.CanonicalKey(args[0])
 			if err != nil {
 				return err
 			}
+			if err := config.ValidateValue(key, args[1]); err != nil {
+				return err
+			}
+			if err := db.SetConfig(key, args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("✅ %s = %s\n", key, args[1])
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "unset [key]",
+		Short: "Remove a configuration override",
+		Long:  `Remove a value saved with 'azguard config set' so the config file or default applies again.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := config.CanonicalKey(args[0])
+			if err != nil {
+				return err
+			}
+			if err := db.DeleteConfig(key); err != nil {
+				return err
+			}
+			fmt.Printf("✅ %s override removed\n", key)
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+func costCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cost",
+		Short: "Advanced cost management",
+	}
+
+	cmd.AddCommand(costCurrentCmd())
+	cmd.AddCommand(costWatchCmd())
+
+	cmd.AddCommand(costSummaryCmd())
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "all",
+		Short: "Show this month's costs across all cloud providers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			summary, err := costSvc.GetMultiCloudSummary(ctx)
+			if err != nil {
+				return err
+			}
+			return printMultiCloudSummary(summary)
+		},
+	})
+
+	cmd.AddCommand(costTopCmd())
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "subscriptions",
+		Short: "List subscriptions with stored costs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := db.GetSubscriptionIDs()
+			if err != nil {
+				return err
+			}
+
+			if outputFormat == "json" {
+				b, err := json.MarshalIndent(ids, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+
+			if len(ids) == 0 {
+				fmt.Println("No costs recorded yet. Run 'azguard cost fetch' first.")
+				return nil
+			}
+			for _, id := range ids {
+				fmt.Println(id)
+			}
+			return nil
+		},
+	})
+	cmd.AddCommand(costFreeTierCmd())
+	cmd.AddCommand(costAnomaliesCmd())
+	cmd.AddCommand(costCompareCmd())
+	cmd.AddCommand(costPruneCmd())
+	cmd.AddCommand(costRecordsCmd())
+	cmd.AddCommand(costExportCmd())
+	cmd.AddCommand(costImportCmd())
+
+	cmd.AddCommand(costFetchCmd())
+	cmd.AddCommand(costReconcileCmd())
+
+	cmd.AddCommand(costHistoryCmd())
+
+	cmd.AddCommand(costForecastCmd())
+
+	cmd.AddCommand(costEstimateCmd())
+
+	cmd.AddCommand(costReportCmd())
+
+	cmd.AddCommand(costTrendCmd())
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "burn",
+		Short: "Project billing-period spend from the daily burn rate",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			burn, err := costSvc.GetBurnRate(ctx)
+			if err != nil {
+				return err
+			}
+
+			if outputFormat == "json" {
+				b, err := json.MarshalIndent(burn, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+
+			fmt.Println("\n🔥 Burn Rate")
+			fmt.Println("─────────────────────────────")
+			fmt.Printf("Billing period:       %s until %s\n", burn.PeriodStart, burn.PeriodEnd)
+			fmt.Printf("Period to date:       %s (%d days)\n", cost.FormatMoney(burn.MTDSpend, burn.Currency), burn.DaysElapsed)
+			fmt.Printf("Daily average:        %s\n", cost.FormatMoney(burn.DailyAverage, burn.Currency))
+			fmt.Printf("Projected period end: %s (%d days remaining)\n", cost.FormatMoney(burn.ProjectedMonthEnd, burn.Currency), burn.DaysRemaining)
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+func costFetchCmd() *cobra.Command {
+	var dryRun, full bool
+	var granularity string
+	cmd := &cobra.Command{
+		Use:   "fetch",
+		Short: "Fetch and store costs from Azure",
+		Long: `Fetch this month's costs and store them.
+
+Each subscription is fetched from the day after its last fetch, re-fetching
+the last couple of days since Azure may still revise them. --full fetches
+the whole month again.
+
+Costs are stored per service and resource group, which resource group
+budgets, summaries and trends rely on; costs stored by older versions have
+no resource group until they are fetched again with --full.
+
+--granularity resource stores costs per resource and meter instead of per
+service, and --granularity service goes back to service totals. Without it,
+each subscription is fetched at the granularity it was last fetched at, so
+later fetches (including 'cost current') keep resource detail once it has
+been requested.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := costSvc.SetFetchGranularity(granularity); err != nil {
+				return err
+			}
+			ctx := context.Background()
+			startDate, endDate := cost.GetCurrentMonthDateRange()
+			if !dryRun {
+				fetch := costSvc.FetchAndStoreCosts
+				if full {
+					fetch = costSvc.FetchAndStoreAllCosts
+				}
+				if err := fetch(ctx, startDate, endDate); err != nil {
+					return err
+				}
+				statusf("✅ Costs fetched and stored\n")
+				return nil
+			}
+
+			records, err := costSvc.FetchCosts(ctx, startDate, endDate)
+			if err != nil {
+				return err
+			}
+			totals := make(map[string]float64)
+			for _, r := range records {
+				totals[r.Currency] += r.Cost
+			}
+			currencies := make([]string, 0, len(totals))
+			for currency := range totals {
+				currencies = append(currencies, currency)
+			}
+			sort.Strings(currencies)
+			amounts := make([]string, 0, len(currencies))
+			for _, currency := range currencies {
+				amounts = append(amounts, cost.FormatMoney(totals[currency], currency))
+			}
+			if len(amounts) == 0 {
+				amounts = append(amounts, cost.FormatMoney(0, costSvc.TargetCurrency()))
+			}
+			fmt.Printf("Dry run: %d records totalling %s would be stored (%s to %s)\n", len(records), strings.Join(amounts, " + "), startDate, endDate)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Fetch and summarize costs without storing them")
+	cmd.Flags().BoolVar(&full, "full", false, "Fetch the whole month, not just the days since the last fetch")
+	cmd.Flags().StringVar(&granularity, "granularity", cost.GranularityAuto, "Cost detail to store: auto, service, resource")
+
+	return cmd
+}
+
+func costEstimateCmd() *cobra.Command {
+	var query azure.PriceQuery
+	var hours float64
+	cmd := &cobra.Command{
+		Use:   "estimate",
+		Short: "Estimate what a resource would cost before deploying it",
+		Long: `Look up the pay-as-you-go list price of a SKU in the Azure Retail Prices
+API and estimate its cost over --hours (default a month). Discounts and
+reservations are not taken into account.
+
+Example:
+  azguard cost estimate --service VirtualMachines --sku Standard_D2s_v3 --region eastus`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if hours <= 0 {
+				return fmt.Errorf("--hours must be positive")
+			}
+			if query.Currency == "" {
+				query.Currency = cfg.Currency.Target
+			}
+
+			estimate, err := azure.NewPricingClient().EstimateCost(context.Background(), query, hours)
+			if err != nil {
+				return err
+			}
+
+			if outputFormat == "json" {
+				b, err := json.MarshalIndent(estimate, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+
+			fmt.Printf("\n💡 Cost Estimate - %s in %s\n", estimate.SKU, estimate.Region)
+			fmt.Println("─────────────────────────────")
+			fmt.Printf("Product:    %s (%s)\n", estimate.Product, estimate.Meter)
+			fmt.Printf("Unit price: %s %s/hour\n", strconv.FormatFloat(estimate.HourlyPrice, 'f', -1, 64), estimate.Currency)
+			fmt.Printf("Estimate:   %s for %s hours\n", cost.FormatMoney(estimate.Cost, estimate.Currency), strconv.FormatFloat(estimate.Hours, 'f', -1, 64))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&query.Service, "service", "VirtualMachines", "Azure service name, e.g. VirtualMachines or \"SQL Database\"")
+	cmd.Flags().StringVar(&query.SKU, "sku", "", "ARM SKU name, e.g. Standard_D2s_v3")
+	cmd.Flags().StringVar(&query.Region, "region", "", "ARM region name, e.g. eastus")
+	cmd.Flags().Float64Var(&hours, "hours", azure.HoursPerMonth, "Hours the resource runs")
+	cmd.Flags().StringVar(&query.Currency, "currency", "", "Price currency (default currency.target)")
+	_ = cmd.MarkFlagRequired("sku")
+	_ = cmd.MarkFlagRequired("region")
+
+	return cmd
+}
+
+func costReconcileCmd() *cobra.Command {
+	var startDate, endDate string
+	var tolerance float64
+	cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Compare stored costs with what Azure currently reports",
+		Long: `Fetch live costs for a date range without storing them and compare each
+service's total with the stored records, to catch ingestion drift such as
+missed days or costs Azure has since revised. Defaults to this month.
+
+Example:
+  azguard cost reconcile --start 2024-01-01 --end 2024-01-31 --tolerance 0.5`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if startDate == "" && endDate == "" {
+				startDate, endDate = cost.GetCurrentMonthDateRange()
+			}
+			if startDate == "" || endDate == "" {
+				return fmt.Errorf("--start and --end must be given together")
+			}
+			if err := cost.ValidateDateRange(startDate, endDate); err != nil {
+				return err
+			}
+			if tolerance < 0 {
+				return fmt.Errorf("--tolerance must not be negative")
+			}
+
+			result, err := costSvc.Reconcile(context.Background(), startDate, endDate, tolerance)
+			if err != nil {
+				return err
+			}
+
+			if outputFormat == "json" {
+				b, err := json.MarshalIndent(result, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+
+			fmt.Printf("\n🔎 Cost Reconciliation - %s\n", result.Period)
+			fmt.Println("─────────────────────────────")
+			fmt.Printf("Stored: %s\n", cost.FormatMoney(result.StoredTotal, result.Currency))
+			fmt.Printf("Live:   %s\n", cost.FormatMoney(result.LiveTotal, result.Currency))
+
+			if len(result.Discrepancies) == 0 {
+				fmt.Printf("\n✅ All %d services match within %s\n", result.Services, cost.FormatMoney(tolerance, result.Currency))
+				return nil
+			}
+
+			fmt.Printf("\n⚠️  %d of %d services differ by more than %s:\n", len(result.Discrepancies), result.Services, cost.FormatMoney(tolerance, result.Currency))
+			for _, d := range result.Discrepancies {
+				fmt.Printf("  %-24s stored %s, live %s (%s)\n", d.Service+":", cost.FormatMoney(d.Stored, result.Currency), cost.FormatMoney(d.Live, result.Currency), signedMoney(d.Difference, result.Currency))
+			}
+			fmt.Println("\nRun 'azguard cost fetch --full' to refresh the stored records.")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&startDate, "start", "", "Start date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&endDate, "end", "", "End date (YYYY-MM-DD)")
+	cmd.Flags().Float64Var(&tolerance, "tolerance", 0.01, "Largest per-service difference to ignore")
+
+	return cmd
+}
+
+func costReportCmd() *cobra.Command {
+	var format, outPath string
+	var emailTo []string
+	var topN int
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Generate a cost report for the last 12 months",
+		Long: `Generate a cost report. By default it is printed in the --output format;
+--format html renders a standalone page that can be printed to PDF.
+
+--email sends the report through the SMTP server in the email config section,
+as HTML with --format html and as Markdown otherwise.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "" && format != "html" {
+				return fmt.Errorf("unknown report format %q (use html, or --output for text formats)", format)
+			}
+
+			if len(emailTo) > 0 {
+				if err := costSvc.EmailReport(emailTo, format == "html", topN); err != nil {
+					return fmt.Errorf("failed to email report: %w", err)
+				}
+				fmt.Printf("✅ Report emailed to %s\n", strings.Join(emailTo, ", "))
+				return nil
+			}
+
+			if format != "html" {
+				report, err := costSvc.GenerateReport(topN)
+				if err != nil {
+					return err
+				}
+				return printReport(report)
+			}
+
+			html, err := costSvc.GenerateHTMLReport(topN)
+			if err != nil {
+				return err
+			}
+			if outPath == "" {
+				fmt.Print(html)
+				return nil
+			}
+			if err := os.WriteFile(outPath, []byte(html), 0644); err != nil {
+				return fmt.Errorf("failed to write report: %w", err)
+			}
+			fmt.Printf("✅ Report written to %s\n", outPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "", "Report format: html (default uses --output)")
+	cmd.Flags().StringVar(&outPath, "out", "", "Write the HTML report to this file instead of stdout")
+	cmd.Flags().IntVar(&topN, "top", cost.DefaultReportTopServices, "Number of top services to include")
+	cmd.Flags().StringSliceVar(&emailTo, "email", nil, "Email the report to these addresses instead of printing it")
+
+	return cmd
+}
+
+func costForecastCmd() *cobra.Command {
+	var method string
+	cmd := &cobra.Command{
+		Use:   "forecast",
+		Short: "Show cost forecast",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			forecastMethod, err := cost.ParseForecastMethod(method)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			forecast, err := costSvc.GetForecast(ctx, forecastMethod)
+			if err != nil {
+				return err
+			}
+			return printForecast(forecast)
+		},
+	}
+
+	cmd.Flags().StringVar(&method, "method", string(cost.ForecastLinear), "Forecast method: linear, moving_average, exponential")
+
+	return cmd
+}
+
+func costCurrentCmd() *cobra.Command {
+	var noFetch bool
+	cmd := &cobra.Command{
+		Use:   "current",
+		Short: "Show current month costs",
+		Long: `Fetch this month's costs from Azure and summarize them. With --no-fetch
+only stored costs are used and Azure is not contacted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			var summary *cost.CostSummary
+			var err error
+			if noFetch {
+				summary, err = costSvc.GetStoredCurrentCosts(ctx)
+			} else {
+				summary, err = costSvc.GetCurrentCosts(ctx)
+			}
+			if err != nil {
+				return err
+			}
+			return printCostSummary(summary)
+		},
+	}
+
+	cmd.Flags().BoolVar(&noFetch, "no-fetch", false, "Use stored costs only; don't query Azure")
+	return cmd
+}
+
+func costWatchCmd() *cobra.Command {
+	var interval time.Duration
+	var noFetch bool
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Show current month costs, refreshing periodically",
+		Long: `Re-render the current month's cost summary every --interval until
+interrupted with Ctrl-C, listing what changed since the previous refresh.
+With --no-fetch only stored costs are shown, which suits watching a
+database that another process keeps up to date.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			return watchCosts(ctx, ticker.C, noFetch, interval)
+		},
+	}
+
+	cmd.Flags().DurationVar(&interval, "interval", 30*time.Second, "Time between refreshes")
+	cmd.Flags().BoolVar(&noFetch, "no-fetch", false, "Use stored costs only; don't query Azure")
+	return cmd
+}
+
+// watchCosts renders the current month's summary now and again on every
+// tick until ctx is cancelled.
+func watchCosts(ctx context.Context, ticks <-chan time.Time, noFetch bool, interval time.Duration) error {
+	var prev *cost.CostSummary
+	for {
+		var summary *cost.CostSummary
+		var err error
+		if noFetch {
+			summary, err = costSvc.GetStoredCurrentCosts(ctx)
+		} else {
+			summary, err = costSvc.GetCurrentCosts(ctx)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			// Keep watching; the next refresh may succeed
+			slog.Error("failed to refresh costs", "err", err)
+		} else {
+			if outputFormat != "json" {
+				// Clear the screen and move the cursor home
+				fmt.Print("\033[H\033[2J")
+			}
+			if err := printCostSummary(summary); err != nil {
+				return err
+			}
+			if outputFormat != "json" {
+				printSummaryChanges(prev, summary)
+				fmt.Printf("\nUpdated %s; refreshing every %s (Ctrl-C to stop)\n", time.Now().Format("15:04:05"), interval)
+			}
+			prev = summary
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticks:
+		}
+	}
+}
+
+// printSummaryChanges lists the total and each service whose cost differs
+// between two refreshes. Nothing is printed for the first refresh.
+func printSummaryChanges(prev, cur *cost.CostSummary) {
+	if prev == nil {
+		return
+	}
+
+	changed := func(a, b float64) bool {
+		return math.Round(a*100) != math.Round(b*100)
+	}
+
+	var lines []string
+	if changed(prev.TotalCost, cur.TotalCost) {
+		lines = append(lines, fmt.Sprintf("  %-20s %s", "Total:", signedMoney(cur.TotalCost-prev.TotalCost, cur.Currency)))
+	}
+
+	services := make(map[string]bool)
+	for name := range prev.ByService {
+		services[name] = true
+	}
+	for name := range cur.ByService {
+		services[name] = true
+	}
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if changed(prev.ByService[name], cur.ByService[name]) {
+			lines = append(lines, fmt.Sprintf("  %-20s %s", name+":", signedMoney(cur.ByService[name]-prev.ByService[name], cur.Currency)))
+		}
+	}
+
+	fmt.Println("\nChanges since last refresh:")
+	if len(lines) == 0 {
+		fmt.Println("  none")
+		return
+	}
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+}
+
+func signedMoney(amount float64, currency string) string {
+	if amount > 0 {
+		return "+" + cost.FormatMoney(amount, currency)
+	}
+	return cost.FormatMoney(amount, currency)
+}
+
+func costTrendCmd() *cobra.Command {
+	var resourceGroup string
+	cmd := &cobra.Command{
+		Use:   "trend",
+		Short: "Show month-over-month cost trend",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			trend, err := costSvc.GetTrendAnalysisByResourceGroup(resourceGroup)
+			if err != nil {
+				return err
+			}
+			return printTrendAnalysis(trend)
+		},
+	}
+
+	cmd.Flags().StringVar(&resourceGroup, "resource-group", "", "Only analyze costs from this resource group")
+	return cmd
+}
+
+func costHistoryCmd() *cobra.Command {
+	var since, until, granularity, service string
+	var weeks int
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show cost history",
+		Long: `Show stored cost history with a monthly breakdown, or a weekly one with
+--granularity weekly. --since and --until narrow it to a date range.
+
+Example:
+  azguard cost history --since 3m
+  azguard cost history --granularity weekly --weeks 8
+  azguard cost history --service "Virtual Machines"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if granularity != "monthly" && granularity != "weekly" {
+				return fmt.Errorf("unsupported granularity %q (use monthly or weekly)", granularity)
+			}
+			if weeks <= 0 {
+				return fmt.Errorf("--weeks must be positive")
+			}
+
+			filter := cost.CostFilter{ServiceName: service}
+			if since == "" && until == "" {
+				filter.StartDate, filter.EndDate = cost.GetLastNMonths(30)
+			} else if err := resolveRelativeDates(since, until, &filter.StartDate, &filter.EndDate); err != nil {
+				return err
+			}
+			if err := cost.ValidateDateRange(filter.StartDate, filter.EndDate); err != nil {
+				return err
+			}
+
+			summary, err := costSvc.GetCostHistoryRange(filter)
+			if err != nil {
+				return err
+			}
+
+			if granularity == "weekly" {
+				summary.MonthlyBreakdown = nil
+				summary.WeeklyBreakdown, err = costSvc.GetWeeklyCosts(weeks, filter)
+				if err != nil {
+					return err
+				}
+			}
+			return printCostSummary(summary)
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "Start of the range: YYYY-MM-DD, today, yesterday, last-month, or an age like 30d, 2w, 3m")
+	cmd.Flags().StringVar(&until, "until", "", "End of the range, in the same forms as --since")
+	cmd.Flags().StringVar(&granularity, "granularity", "monthly", "Breakdown granularity: monthly, weekly")
+	cmd.Flags().IntVar(&weeks, "weeks", 12, "Number of weeks to break down with --granularity weekly")
+	cmd.Flags().StringVar(&service, "service", "", "Only include costs from this service")
+
+	return cmd
+}
+
+// addRelativeDateFlags adds --since and --until as alternatives to a
+// command's --start and --end flags.
+func addRelativeDateFlags(cmd *cobra.Command, since, until *string) {
+	cmd.Flags().StringVar(since, "since", "", "Start of the range: YYYY-MM-DD, today, yesterday, last-month, or an age like 30d, 2w, 3m")
+	cmd.Flags().StringVar(until, "until", "", "End of the range, in the same forms as --since")
+	cmd.MarkFlagsMutuallyExclusive("since", "start")
+	cmd.MarkFlagsMutuallyExclusive("until", "end")
+}
+
+// resolveRelativeDates sets start and end from the --since and --until
+// expressions that were given.
+func resolveRelativeDates(since, until string, start, end *string) error {
+	now := time.Now()
+	if since != "" {
+		s, _, err := cost.ParseDateExpr(since, now)
+		if err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+		*start = s
+	}
+	if until != "" {
+		_, e, err := cost.ParseDateExpr(until, now)
+		if err != nil {
+			return fmt.Errorf("--until: %w", err)
+		}
+		*end = e
+	}
+	return nil
+}
+
+func costSummaryCmd() *cobra.Command {
+	var filter cost.CostFilter
+	var daily bool
+	var since, until string
+	cmd := &cobra.Command{
+		Use:   "summary",
+		Short: "Show stored costs for a date range",
+		Long: `Summarize stored cost records without fetching from the cloud provider.
+
+Example:
+  azguard cost summary --since 30d
+  azguard cost summary --since last-month --until last-month`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := resolveRelativeDates(since, until, &filter.StartDate, &filter.EndDate); err != nil {
+				return err
+			}
+			if err := cost.ValidateDateRange(filter.StartDate, filter.EndDate); err != nil {
+				return err
+			}
+			summary, err := costSvc.GetCostSummary(filter)
+			if err != nil {
+				return err
+			}
+			if daily {
+				summary.Daily, err = costSvc.GetDailyCosts(filter)
+				if err != nil {
+					return err
+				}
+			}
 			return printCostSummary(summary)
 		},
-	})
+	}
+
+	cmd.Flags().BoolVar(&daily, "daily", false, "Include a day-by-day breakdown")
+	cmd.Flags().StringVar(&filter.StartDate, "start", "", "Start date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&filter.EndDate, "end", "", "End date (YYYY-MM-DD)")
+	addRelativeDateFlags(cmd, &since, &until)
+	cmd.Flags().StringVar(&filter.Provider, "provider", "", "Only include costs from this provider (e.g. azure, aws, gcp)")
+	cmd.Flags().StringVar(&filter.SubscriptionID, "subscription", "", "Only include costs from this subscription ID")
+	cmd.Flags().StringVar(&filter.ResourceGroup, "resource-group", "", "Only include costs from this resource group")
+
+	return cmd
+}
+
+func costTopCmd() *cobra.Command {
+	var filter cost.CostFilter
+	var n int
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Show the most expensive services for a date range",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cost.ValidateDateRange(filter.StartDate, filter.EndDate); err != nil {
+				return err
+			}
+
+			if n <= 0 {
+				return fmt.Errorf("--n must be positive")
+			}
+
+			services, currency, err := costSvc.GetTopServices(filter, n)
+			if err != nil {
+				return err
+			}
+
+			if outputFormat == "json" {
+				b, err := json.MarshalIndent(services, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+
+			fmt.Printf("\n🏆 Top %d Services (%s)\n", n, currency)
+			fmt.Println("─────────────────────────────────")
+			if len(services) == 0 {
+				fmt.Println("No costs recorded for this range.")
+				return nil
+			}
+			for i, svc := range services {
+				fmt.Printf("%2d. %-24s %12s  %5.1f%%\n", i+1, svc.Service, cost.FormatMoney(svc.Cost, currency), svc.Percent)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&n, "n", 10, "Number of services to show")
+	cmd.Flags().StringVar(&filter.StartDate, "start", "", "Start date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&filter.EndDate, "end", "", "End date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&filter.Provider, "provider", "", "Only include costs from this provider (e.g. azure, aws, gcp)")
+	cmd.Flags().StringVar(&filter.SubscriptionID, "subscription", "", "Only include costs from this subscription ID")
+	cmd.Flags().StringVar(&filter.ResourceGroup, "resource-group", "", "Only include costs from this resource group")
+
+	return cmd
+}
+
+func costFreeTierCmd() *cobra.Command {
+	var filter cost.CostFilter
+	cmd := &cobra.Command{
+		Use:   "freetier",
+		Short: "Compare stored spend with free tier allowances",
+		Long: `Check each stored service against its free tier entry. Defaults to the
+current month.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cost.ValidateDateRange(filter.StartDate, filter.EndDate); err != nil {
+				return err
+			}
+
+			if filter.StartDate == "" && filter.EndDate == "" {
+				filter.StartDate, filter.EndDate = cost.GetCurrentMonthDateRange()
+			}
+
+			usages, err := costSvc.CheckFreeTier(filter)
+			if err != nil {
+				return err
+			}
+
+			if outputFormat == "json" {
+				b, err := json.MarshalIndent(usages, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+
+			fmt.Println("\n🆓 Free Tier Usage")
+			fmt.Println("─────────────────────────────────")
+			if len(usages) == 0 {
+				fmt.Println("No stored costs match a free tier service.")
+				return nil
+			}
+			for _, u := range usages {
+				marker := "✅"
+				switch u.Status {
+				case cost.StatusWarning:
+					marker = "⚠️ "
+				case cost.StatusOverage:
+					marker = "❌"
+				case cost.StatusUnknown:
+					marker = "❔"
+				}
+				if u.Status == cost.StatusUnknown {
+					fmt.Printf("%s %-20s %s (no free_value configured)\n", marker, u.ServiceName+":", cost.FormatMoney(u.Used, u.Unit))
+					continue
+				}
+				fmt.Printf("%s %-20s %s / %s (%.0f%%) %s\n", marker, u.ServiceName+":", cost.FormatMoney(u.Used, u.Unit), cost.FormatMoney(u.Limit, u.Unit), u.PercentUsed, u.Status)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&filter.StartDate, "start", "", "Start date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&filter.EndDate, "end", "", "End date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&filter.Provider, "provider", "", "Only include costs from this provider (e.g. azure, aws, gcp)")
+	cmd.Flags().StringVar(&filter.SubscriptionID, "subscription", "", "Only include costs from this subscription ID")
+	cmd.Flags().StringVar(&filter.ResourceGroup, "resource-group", "", "Only include costs from this resource group")
+
+	return cmd
+}
+
+func costAnomaliesCmd() *cobra.Command {
+	var filter cost.CostFilter
+	var threshold float64
+	cmd := &cobra.Command{
+		Use:   "anomalies",
+		Short: "Detect days with unusually high spend",
+		Long:  `Flag days whose spend is well above the average of the preceding days.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cost.ValidateDateRange(filter.StartDate, filter.EndDate); err != nil {
+				return err
+			}
+			anomalies, err := costSvc.DetectAnomalies(filter, threshold)
+			if err != nil {
+				return err
+			}
+
+			if outputFormat == "json" {
+				b, err := json.MarshalIndent(anomalies, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+
+			if len(anomalies) == 0 {
+				fmt.Println("✅ No cost anomalies detected.")
+				return nil
+			}
+
+			fmt.Println("\n📈 Cost Anomalies")
+			fmt.Println("─────────────────────────────")
+			for _, a := range anomalies {
+				fmt.Printf("%s  %s (expected ~%s, %s)\n", a.Date, cost.FormatMoney(a.Cost, a.Currency), cost.FormatMoney(a.Expected, a.Currency), signedMoney(a.Deviation, a.Currency))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&filter.StartDate, "start", "", "Start date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&filter.EndDate, "end", "", "End date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&filter.Provider, "provider", "", "Only include costs from this provider (e.g. azure, aws, gcp)")
+	cmd.Flags().StringVar(&filter.SubscriptionID, "subscription", "", "Only include costs from this subscription ID")
+	cmd.Flags().StringVar(&filter.ResourceGroup, "resource-group", "", "Only include costs from this resource group")
+	cmd.Flags().Float64Var(&threshold, "threshold", 2, "Standard deviations above the trailing mean that count as a spike")
+
+	return cmd
+}
+
+func costCompareCmd() *cobra.Command {
+	var periodA, periodB string
+	cmd := &cobra.Command{
+		Use:   "compare",
+		Short: "Compare costs between two months",
+		Long: `Show per-service changes in spend from one month to another.
+
+Example:
+  azguard cost compare --period-a 2024-01 --period-b 2024-02`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var a, b cost.CostFilter
+			var err error
+			if a.StartDate, a.EndDate, err = cost.GetMonthDateRange(periodA); err != nil {
+				return err
+			}
+			if b.StartDate, b.EndDate, err = cost.GetMonthDateRange(periodB); err != nil {
+				return err
+			}
+
+			comparison, err := costSvc.ComparePeriods(a, b)
+			if err != nil {
+				return err
+			}
+
+			if outputFormat == "json" {
+				out, err := json.MarshalIndent(comparison, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(out))
+				return nil
+			}
+
+			fmt.Printf("\n📊 Cost Comparison: %s → %s\n", periodA, periodB)
+			fmt.Println("═══════════════════════════════")
+			fmt.Printf("Total: %s → %s (%s, %+.1f%%)\n", cost.FormatMoney(comparison.TotalA, comparison.Currency), cost.FormatMoney(comparison.TotalB, comparison.Currency), signedMoney(comparison.Delta, comparison.Currency), comparison.PercentChange)
+
+			if len(comparison.Services) > 0 {
+				fmt.Println("\nBy Service:")
+				for _, d := range comparison.Services {
+					fmt.Printf("  %-20s %s → %s (%s) %s\n", d.Service+":", cost.FormatMoney(d.CostA, comparison.Currency), cost.FormatMoney(d.CostB, comparison.Currency), signedMoney(d.Delta, comparison.Currency), d.Status)
+				}
+			}
+			return nil
+		},
+	}
 
-	cmd.AddCommand(&cobra.Command{
-		Use:   "fetch",
-		Short: "Fetch and store costs from Azure",
+	cmd.Flags().StringVar(&periodA, "period-a", "", "Baseline month (YYYY-MM)")
+	cmd.Flags().StringVar(&periodB, "period-b", "", "Month to compare against the baseline (YYYY-MM)")
+	_ = cmd.MarkFlagRequired("period-a")
+	_ = cmd.MarkFlagRequired("period-b")
+
+	return cmd
+}
+
+func costPruneCmd() *cobra.Command {
+	var olderThan string
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete old cost records",
+		Long:  `Remove stored cost records older than a given age to keep the database small.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := context.Background()
-			startDate, endDate := cost.GetCurrentMonthDateRange()
-			if err := costSvc.FetchAndStoreCosts(ctx, startDate, endDate); err != nil {
+			before, err := cost.GetCutoffDate(olderThan, time.Now())
+			if err != nil {
+				return err
+			}
+
+			removed, err := db.PruneCostRecords(before)
+			if err != nil {
 				return err
 			}
-			fmt.Println("✅ Costs fetched and stored")
+
+			fmt.Printf("✅ Removed %d cost records dated before %s\n", removed, before)
 			return nil
 		},
-	})
+	}
 
-	cmd.AddCommand(&cobra.Command{
-		Use:   "history",
-		Short: "Show cost history",
+	cmd.Flags().StringVar(&olderThan, "older-than", "365d", "Delete records older than this age (e.g. 90d, 12w, 6m, 1y)")
+
+	return cmd
+}
+
+func costRecordsCmd() *cobra.Command {
+	var filter storage.CostFilter
+	cmd := &cobra.Command{
+		Use:   "records",
+		Short: "List stored cost records",
+		Long: `List individual stored cost records, newest first, for checking what a
+fetch or import stored. Use --limit and --offset to page through them.
+With --output json the page is an object holding the records along with
+their count, limit and offset.
+
+Example:
+  azguard cost records --service "Virtual Machines" --start 2024-01-01 --limit 20`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			summary, err := costSvc.GetCostHistory(30)
+			if err := cost.ValidateDateRange(filter.StartDate, filter.EndDate); err != nil {
+				return err
+			}
+			if filter.Limit < 0 || filter.Offset < 0 {
+				return fmt.Errorf("--limit and --offset must not be negative")
+			}
+
+			records, err := db.GetCostRecords(filter)
 			if err != nil {
 				return err
 			}
-			return printCostSummary(summary)
+			return printCostRecords(records, filter)
 		},
-	})
+	}
 
-	cmd.AddCommand(&cobra.Command{
-		Use:   "forecast",
-		Short: "Show cost forecast",
+	cmd.Flags().StringVar(&filter.StartDate, "start", "", "Start date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&filter.EndDate, "end", "", "End date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&filter.ServiceName, "service", "", "Only include records for this service")
+	cmd.Flags().StringVar(&filter.Provider, "provider", "", "Only include records from this provider (e.g. azure, aws, gcp)")
+	cmd.Flags().IntVar(&filter.Limit, "limit", 50, "Maximum number of records to list (0 for all)")
+	cmd.Flags().IntVar(&filter.Offset, "offset", 0, "Number of records to skip")
+
+	return cmd
+}
+
+func costExportCmd() *cobra.Command {
+	var filter storage.CostFilter
+	var format, out string
+	var since, until string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export raw cost records to CSV or JSON",
+		Long: `Write stored cost records for a date range to a file (or stdout).
+
+Example:
+  azguard cost export --format csv --start 2024-01-01 --end 2024-03-31 --out costs.csv
+  azguard cost export --format json --since last-month --until last-month`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := context.Background()
-			forecast, err := costSvc.GetForecast(ctx)
+			if err := resolveRelativeDates(since, until, &filter.StartDate, &filter.EndDate); err != nil {
+				return err
+			}
+			if err := cost.ValidateDateRange(filter.StartDate, filter.EndDate); err != nil {
+				return err
+			}
+
+			if format != "csv" && format != "json" {
+				return fmt.Errorf("unsupported export format %q (use csv or json)", format)
+			}
+
+			w := os.Stdout
+			if out != "" {
+				f, err := os.Create(out)
+				if err != nil {
+					return fmt.Errorf("failed to create %s: %w", out, err)
+				}
+				// Closed explicitly below so a failed write is reported;
+				// this only covers the early returns
+				defer f.Close()
+				w = f
+			}
+
+			var count int
+			var err error
+			if format == "csv" {
+				count, err = exportCSV(w, filter)
+			} else {
+				count, err = exportJSON(w, filter)
+			}
 			if err != nil {
 				return err
 			}
-			fmt.Printf("Next month forecast: $%.2f (confidence: %s)\n", forecast.NextMonth, forecast.Confidence)
+
+			if out != "" {
+				if err := w.Close(); err != nil {
+					return fmt.Errorf("failed to write %s: %w", out, err)
+				}
+				statusf("✅ Exported %d cost records to %s\n", count, out)
+			}
 			return nil
 		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "csv", "Export format: csv, json")
+	cmd.Flags().StringVar(&filter.StartDate, "start", "", "Start date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&filter.EndDate, "end", "", "End date (YYYY-MM-DD)")
+	addRelativeDateFlags(cmd, &since, &until)
+	cmd.Flags().StringVar(&filter.Provider, "provider", "", "Only include costs from this provider (e.g. azure, aws, gcp)")
+	cmd.Flags().StringVar(&filter.SubscriptionID, "subscription", "", "Only include costs from this subscription ID")
+	cmd.Flags().StringVar(&filter.ResourceGroup, "resource-group", "", "Only include costs from this resource group")
+	cmd.Flags().StringVar(&out, "out", "", "Output file (default stdout)")
+
+	return cmd
+}
+
+var exportColumns = []string{"subscription_id", "resource_group", "service_name", "cost", "currency", "date", "provider", "resource_id", "meter"}
+
+func exportCSV(w io.Writer, filter storage.CostFilter) (int, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := db.EachCostRecord(filter, func(r storage.CostRecord) error {
+		count++
+		return cw.Write([]string{
+			r.SubscriptionID,
+			r.ResourceGroup,
+			r.ServiceName,
+			strconv.FormatFloat(r.Cost, 'f', -1, 64),
+			r.Currency,
+			r.Date,
+			r.Provider,
+			r.ResourceID,
+			r.Meter,
+		})
+	})
+	if err != nil {
+		return count, err
+	}
+
+	cw.Flush()
+	return count, cw.Error()
+}
+
+type exportRecord struct {
+	SubscriptionID string  `json:"subscription_id"`
+	ResourceGroup  string  `json:"resource_group"`
+	ServiceName    string  `json:"service_name"`
+	Cost           float64 `json:"cost"`
+	Currency       string  `json:"currency"`
+	Date           string  `json:"date"`
+	Provider       string  `json:"provider"`
+	ResourceID     string  `json:"resource_id,omitempty"`
+	Meter          string  `json:"meter,omitempty"`
+}
+
+// exportJSON writes a JSON array one element at a time so large exports are
+// never held in memory.
+func exportJSON(w io.Writer, filter storage.CostFilter) (int, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := db.EachCostRecord(filter, func(r storage.CostRecord) error {
+		b, err := json.Marshal(exportRecord{
+			SubscriptionID: r.SubscriptionID,
+			ResourceGroup:  r.ResourceGroup,
+			ServiceName:    r.ServiceName,
+			Cost:           r.Cost,
+			Currency:       r.Currency,
+			Date:           r.Date,
+			Provider:       r.Provider,
+			ResourceID:     r.ResourceID,
+			Meter:          r.Meter,
+		})
+		if err != nil {
+			return err
+		}
+
+		sep := "\n  "
+		if count > 0 {
+			sep = ",\n  "
+		}
+		count++
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
 	})
+	if err != nil {
+		return count, err
+	}
+
+	_, err = io.WriteString(w, "\n]\n")
+	return count, err
+}
+
+// importBatchSize bounds how many records are written per transaction.
+const importBatchSize = 500
+
+func costImportCmd() *cobra.Command {
+	var format, file string
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import cost records from a CSV or JSON file",
+		Long: `Load cost records from a file in the layout written by 'cost export'.
+
+CSV files need a header row with at least service_name, cost and date;
+subscription_id, resource_group, currency and provider are optional. JSON
+files are an array of objects with the same field names. Rows that can't be
+parsed are skipped and reported. Records that already exist are replaced.
+
+Example:
+  azguard cost import --format csv --file costs.csv`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "csv" && format != "json" {
+				return fmt.Errorf("unsupported import format %q (use csv or json)", format)
+			}
+			if file == "" {
+				return fmt.Errorf("--file is required")
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", file, err)
+			}
+			defer f.Close()
+
+			var records []storage.CostRecord
+			var skipped []string
+			if format == "csv" {
+				records, skipped, err = importCSV(f)
+			} else {
+				records, skipped, err = importJSON(f)
+			}
+			if err != nil {
+				return err
+			}
+
+			for start := 0; start < len(records); start += importBatchSize {
+				end := start + importBatchSize
+				if end > len(records) {
+					end = len(records)
+				}
+				if err := db.SaveCostRecords(records[start:end]); err != nil {
+					return fmt.Errorf("failed to save records (%d imported so far): %w", start, err)
+				}
+			}
+
+			for _, reason := range skipped {
+				slog.Warn("skipped import row", "file", file, "reason", reason)
+			}
+			statusf("✅ Imported %d cost records from %s (%d skipped)\n", len(records), file, len(skipped))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "csv", "Import format: csv, json")
+	cmd.Flags().StringVar(&file, "file", "", "File to import")
 
 	return cmd
 }
 
+var requiredImportColumns = []string{"service_name", "cost", "date"}
+
+// importCSV parses records from a CSV file with a header row. It returns the
+// valid records and a description of every skipped row.
+func importCSV(r io.Reader) ([]storage.CostRecord, []string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredImportColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("CSV is missing required column %q", name)
+		}
+	}
+
+	var records []storage.CostRecord
+	var skipped []string
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		c, err := strconv.ParseFloat(field("cost"), 64)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("line %d: invalid cost %q", line, field("cost")))
+			continue
+		}
+		record := storage.CostRecord{
+			SubscriptionID: field("subscription_id"),
+			ResourceGroup:  field("resource_group"),
+			ServiceName:    field("service_name"),
+			Cost:           c,
+			Currency:       field("currency"),
+			Date:           field("date"),
+			Provider:       field("provider"),
+			ResourceID:     field("resource_id"),
+			Meter:          field("meter"),
+		}
+		if reason := validateImportRecord(&record); reason != "" {
+			skipped = append(skipped, fmt.Sprintf("line %d: %s", line, reason))
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, skipped, nil
+}
+
+// importJSON parses records from a JSON array in the 'cost export' layout.
+func importJSON(r io.Reader) ([]storage.CostRecord, []string, error) {
+	var rows []exportRecord
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	var records []storage.CostRecord
+	var skipped []string
+	for i, row := range rows {
+		record := storage.CostRecord{
+			SubscriptionID: row.SubscriptionID,
+			ResourceGroup:  row.ResourceGroup,
+			ServiceName:    row.ServiceName,
+			Cost:           row.Cost,
+			Currency:       row.Currency,
+			Date:           row.Date,
+			Provider:       row.Provider,
+			ResourceID:     row.ResourceID,
+			Meter:          row.Meter,
+		}
+		if reason := validateImportRecord(&record); reason != "" {
+			skipped = append(skipped, fmt.Sprintf("record %d: %s", i+1, reason))
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, skipped, nil
+}
+
+// validateImportRecord fills in defaults and returns why the record can't be
+// imported, or "" if it can.
+func validateImportRecord(r *storage.CostRecord) string {
+	if r.ServiceName == "" {
+		return "missing service_name"
+	}
+	if _, err := time.Parse("2006-01-02", r.Date); err != nil {
+		return fmt.Sprintf("invalid date %q (use YYYY-MM-DD)", r.Date)
+	}
+	if r.Currency == "" {
+		r.Currency = "USD"
+	}
+	r.Currency = strings.ToUpper(r.Currency)
+	return ""
+}
+
 func printCostSummary(summary *cost.CostSummary) error {
 	switch outputFormat {
 	case "json":
@@ -527,16 +2259,259 @@ func printCostSummary(summary *cost.CostSummary) error {
 			return err
 		}
 		fmt.Println(string(b))
+	case "yaml":
+		return printYAML(summary)
 	default:
+		if summary.Warning != "" {
+			slog.Warn(summary.Warning)
+		}
 		fmt.Printf("\n📊 Azure Costs - %s\n", summary.Period)
-		fmt.Printf("Total: $%.2f %s\n", summary.TotalCost, summary.Currency)
+		fmt.Printf("Total: %s %s\n", cost.FormatMoney(summary.TotalCost, summary.Currency), summary.Currency)
 
 		if len(summary.ByService) > 0 {
 			fmt.Println("\nBy Service:")
 			for service, c := range summary.ByService {
-				fmt.Printf("  %-20s $%.2f\n", service+":", c)
+				fmt.Printf("  %-20s %s\n", service+":", cost.FormatMoney(c, summary.Currency))
+			}
+		}
+
+		if len(summary.Daily) > 0 {
+			fmt.Println("\nBy Day:")
+			for _, d := range summary.Daily {
+				fmt.Printf("  %-20s %s\n", d.Date+":", cost.FormatMoney(d.TotalCost, d.Currency))
+			}
+		}
+
+		if len(summary.WeeklyBreakdown) > 0 {
+			fmt.Println("\nBy Week:")
+			for _, w := range summary.WeeklyBreakdown {
+				fmt.Printf("  %-20s %s\n", "Week of "+w.Week+":", cost.FormatMoney(w.TotalCost, w.Currency))
+			}
+		}
+	}
+	return nil
+}
+
+// costRecordsPage is the JSON form of 'cost records': one page of records
+// with the paging that selected it.
+type costRecordsPage struct {
+	Records []exportRecord `json:"records"`
+	Count   int            `json:"count"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+}
+
+func printCostRecords(records []storage.CostRecord, filter storage.CostFilter) error {
+	switch outputFormat {
+	case "json":
+		rows := make([]exportRecord, 0, len(records))
+		for _, r := range records {
+			rows = append(rows, exportRecord{
+				SubscriptionID: r.SubscriptionID,
+				ResourceGroup:  r.ResourceGroup,
+				ServiceName:    r.ServiceName,
+				Cost:           r.Cost,
+				Currency:       r.Currency,
+				Date:           r.Date,
+				Provider:       r.Provider,
+				ResourceID:     r.ResourceID,
+				Meter:          r.Meter,
+			})
+		}
+		b, err := json.MarshalIndent(costRecordsPage{
+			Records: rows,
+			Count:   len(rows),
+			Limit:   filter.Limit,
+			Offset:  filter.Offset,
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		if err := w.Write(exportColumns); err != nil {
+			return err
+		}
+		for _, r := range records {
+			if err := w.Write([]string{
+				r.SubscriptionID,
+				r.ResourceGroup,
+				r.ServiceName,
+				strconv.FormatFloat(r.Cost, 'f', -1, 64),
+				r.Currency,
+				r.Date,
+				r.Provider,
+				r.ResourceID,
+				r.Meter,
+			}); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	case "markdown":
+		fmt.Println("| Date | Provider | Subscription | Service | Resource Group | Cost |")
+		fmt.Println("|------|----------|--------------|---------|----------------|-----:|")
+		for _, r := range records {
+			fmt.Printf("| %s | %s | %s | %s | %s | %s |\n", r.Date, r.Provider, r.SubscriptionID, r.ServiceName, r.ResourceGroup, cost.FormatMoney(r.Cost, r.Currency))
+		}
+	default:
+		if len(records) == 0 {
+			fmt.Println("No cost records match.")
+			return nil
+		}
+		fmt.Printf("%-10s  %-8s  %-24s  %-20s  %12s\n", "Date", "Provider", "Service", "Resource Group", "Cost")
+		for _, r := range records {
+			fmt.Printf("%-10s  %-8s  %-24s  %-20s  %12s\n", r.Date, r.Provider, r.ServiceName, r.ResourceGroup, cost.FormatMoney(r.Cost, r.Currency))
+		}
+		fmt.Printf("\n%d record(s)", len(records))
+		if filter.Offset > 0 {
+			fmt.Printf(" from offset %d", filter.Offset)
+		}
+		if filter.Limit > 0 && len(records) == filter.Limit {
+			fmt.Printf("; use --offset %d for more", filter.Offset+filter.Limit)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func printReport(report *cost.Report) error {
+	switch outputFormat {
+	case "json":
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case "yaml":
+		return printYAML(report)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		rows := [][]string{
+			{"section", "name", "cost"},
+			{"total", report.Period, strconv.FormatFloat(report.TotalCost, 'f', 2, 64)},
+			{"forecast", "next_month", strconv.FormatFloat(report.Forecast, 'f', 2, 64)},
+		}
+		for _, svc := range report.TopServices {
+			rows = append(rows, []string{"service", svc.Service, strconv.FormatFloat(svc.Cost, 'f', 2, 64)})
+		}
+		for _, m := range report.MonthlyData {
+			rows = append(rows, []string{"month", m.Month, strconv.FormatFloat(m.TotalCost, 'f', 2, 64)})
+		}
+		if err := w.WriteAll(rows); err != nil {
+			return err
+		}
+	case "markdown":
+		fmt.Print(cost.RenderMarkdownReport(report))
+	default:
+		fmt.Printf("\n📄 Cost Report - %s\n", report.Period)
+		fmt.Printf("Generated: %s\n", report.GeneratedAt)
+		fmt.Println("─────────────────────────────")
+		fmt.Printf("Total:    %s %s\n", cost.FormatMoney(report.TotalCost, report.Currency), report.Currency)
+		fmt.Printf("Forecast: %s next month\n", cost.FormatMoney(report.Forecast, report.Currency))
+
+		if len(report.TopServices) > 0 {
+			fmt.Println("\nTop Services:")
+			for _, svc := range report.TopServices {
+				fmt.Printf("  %-20s %s\n", svc.Service+":", cost.FormatMoney(svc.Cost, report.Currency))
 			}
 		}
+		if len(report.MonthlyData) > 0 {
+			fmt.Println("\nMonthly:")
+			for _, m := range report.MonthlyData {
+				fmt.Printf("  %-20s %s\n", m.Month+":", cost.FormatMoney(m.TotalCost, m.Currency))
+			}
+		}
+	}
+	return nil
+}
+
+func printTrendAnalysis(trend *cost.TrendAnalysis) error {
+	switch outputFormat {
+	case "json":
+		b, err := json.MarshalIndent(trend, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case "yaml":
+		return printYAML(trend)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		rows := [][]string{
+			{"metric", "value"},
+			{"current_month", strconv.FormatFloat(trend.CurrentMonth, 'f', 2, 64)},
+			{"previous_month", strconv.FormatFloat(trend.PreviousMonth, 'f', 2, 64)},
+			{"change_percent", strconv.FormatFloat(trend.ChangePercent, 'f', 2, 64)},
+			{"trend", trend.Trend},
+			{"average_monthly", strconv.FormatFloat(trend.AverageMonthly, 'f', 2, 64)},
+			{"projection", strconv.FormatFloat(trend.Projection, 'f', 2, 64)},
+		}
+		if err := w.WriteAll(rows); err != nil {
+			return err
+		}
+	default:
+		if trend.ResourceGroup != "" {
+			fmt.Printf("\n📈 Cost Trend - %s\n", trend.ResourceGroup)
+		} else {
+			fmt.Println("\n📈 Cost Trend")
+		}
+		fmt.Println("─────────────────────────────")
+		fmt.Printf("Current month:   %s\n", cost.FormatMoney(trend.CurrentMonth, trend.Currency))
+		fmt.Printf("Previous month:  %s\n", cost.FormatMoney(trend.PreviousMonth, trend.Currency))
+		fmt.Printf("Change:          %+.1f%% (%s)\n", trend.ChangePercent, trend.Trend)
+		fmt.Printf("Monthly average: %s\n", cost.FormatMoney(trend.AverageMonthly, trend.Currency))
+		fmt.Printf("Projection:      %s\n", cost.FormatMoney(trend.Projection, trend.Currency))
+	}
+	return nil
+}
+
+func printForecast(forecast *cost.Forecast) error {
+	switch outputFormat {
+	case "json":
+		b, err := json.MarshalIndent(forecast, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		rows := [][]string{
+			{"next_month", "confidence"},
+			{strconv.FormatFloat(forecast.NextMonth, 'f', 2, 64), forecast.Confidence},
+		}
+		if err := w.WriteAll(rows); err != nil {
+			return err
+		}
+	default:
+		fmt.Printf("Next month forecast: %s (confidence: %s, method: %s)\n", cost.FormatMoney(forecast.NextMonth, forecast.Currency), forecast.Confidence, forecast.Method)
+	}
+	return nil
+}
+
+func printMultiCloudSummary(summary *cost.MultiCloudSummary) error {
+	if outputFormat == "json" {
+		b, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	providers := make([]string, 0, len(summary.PerProvider))
+	for p := range summary.PerProvider {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+
+	fmt.Printf("\n☁️  Multi-Cloud Costs - %s\n", summary.Period)
+	fmt.Println("─────────────────────────────")
+	for _, p := range providers {
+		fmt.Printf("  %-20s %s\n", p+":", cost.FormatMoney(summary.PerProvider[p], summary.Currency))
 	}
+	fmt.Printf("Grand total: %s\n", cost.FormatMoney(summary.GrandTotal, summary.Currency))
 	return nil
 }
@@ -0,0 +1,889 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azguard/azguard/internal/config"
+	"github.com/azguard/azguard/internal/cost"
+	"github.com/azguard/azguard/internal/storage"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// useTestDB points the command globals at a fresh database, with no cloud
+// providers to fetch from.
+func useTestDB(t *testing.T) *storage.DB {
+	t.Helper()
+	testDB, err := storage.New(filepath.Join(t.TempDir(), "azguard.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { testDB.Close() })
+
+	prevDB, prevSvc := db, costSvc
+	db, costSvc = testDB, cost.NewService(testDB)
+	t.Cleanup(func() { db, costSvc = prevDB, prevSvc })
+	return testDB
+}
+
+// stubProvider serves canned records, or err, and counts its queries.
+type stubProvider struct {
+	records []storage.CostRecord
+	err     error
+	calls   int
+}
+
+func (p *stubProvider) Name() string    { return "azure" }
+func (p *stubProvider) Account() string { return "sub-1" }
+
+func (p *stubProvider) QueryCostsByService(ctx context.Context, startDate, endDate string) ([]storage.CostRecord, error) {
+	p.calls++
+	return p.records, p.err
+}
+
+func (p *stubProvider) GetForecast(ctx context.Context, granularity string) (float64, string, error) {
+	p.calls++
+	return 0, "USD", p.err
+}
+
+// useTestProvider is useTestDB with costSvc fetching from p.
+func useTestProvider(t *testing.T, p cost.CloudCostProvider) *storage.DB {
+	t.Helper()
+	testDB := useTestDB(t)
+	costSvc = cost.NewService(testDB, p)
+	return testDB
+}
+
+// useTestServices is useTestDB holding today's spend.
+func useTestServices(t *testing.T, spend float64) {
+	t.Helper()
+	if err := useTestDB(t).SaveCostRecord(storage.CostRecord{
+		SubscriptionID: "sub-1",
+		ServiceName:    "Storage",
+		Cost:           spend,
+		Currency:       "USD",
+		Date:           time.Now().Format("2006-01-02"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useTestConfig sets the global configuration for one test.
+func useTestConfig(t *testing.T, c *config.Config) {
+	t.Helper()
+	prev := cfg
+	cfg = c
+	t.Cleanup(func() { cfg = prev })
+}
+
+// setOutput sets the global --output and --quiet values for one test.
+func setOutput(t *testing.T, format string, beQuiet bool) {
+	t.Helper()
+	prevFormat, prevQuiet := outputFormat, quiet
+	outputFormat, quiet = format, beQuiet
+	t.Cleanup(func() { outputFormat, quiet = prevFormat, prevQuiet })
+}
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	defer func() { os.Stdout = prev }()
+
+	fn()
+	w.Close()
+	return <-out
+}
+
+// runCommand executes cmd with args and returns its stdout.
+func runCommand(t *testing.T, cmd *cobra.Command, args ...string) (string, error) {
+	t.Helper()
+	cmd.SetArgs(args)
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	var err error
+	out := captureStdout(t, func() { err = cmd.Execute() })
+	return out, err
+}
+
+func runBudgetCheck(t *testing.T, args ...string) error {
+	t.Helper()
+	cmd := budgetCheckCmd()
+	cmd.SetArgs(args)
+	return cmd.Execute()
+}
+
+func TestBudgetCheckFailOnTrigger(t *testing.T) {
+	useTestServices(t, 60)
+	if err := db.SaveAlert(storage.Alert{Name: "budget-50", Threshold: 50, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runBudgetCheck(t); err != nil {
+		t.Errorf("without --fail-on-trigger: %v, want success", err)
+	}
+	err := runBudgetCheck(t, "--fail-on-trigger")
+	if err == nil || !strings.Contains(err.Error(), "1 of 1 budget alerts triggered") {
+		t.Errorf("with --fail-on-trigger: %v, want a triggered alerts error", err)
+	}
+}
+
+func TestBudgetCheckFailOnTriggerPassesUnderBudget(t *testing.T) {
+	useTestServices(t, 45)
+	if err := db.SaveAlert(storage.Alert{Name: "budget-50", Threshold: 50, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A warning is not a trigger
+	if err := runBudgetCheck(t, "--fail-on-trigger"); err != nil {
+		t.Errorf("got %v, want success below the threshold", err)
+	}
+}
+
+// seedRecords saves a few September records for the export and listing tests.
+func seedRecords(t *testing.T, testDB *storage.DB) []storage.CostRecord {
+	t.Helper()
+	records := []storage.CostRecord{
+		{SubscriptionID: "sub-1", ResourceGroup: "web-rg", ServiceName: "Virtual Machines", Cost: 12.5, Currency: "USD", Date: "2026-09-01", Provider: "azure"},
+		{SubscriptionID: "sub-1", ResourceGroup: "data-rg", ServiceName: "Storage", Cost: 3.25, Currency: "USD", Date: "2026-09-02", Provider: "azure"},
+		{SubscriptionID: "123456789012", ServiceName: "Amazon S3", Cost: 4, Currency: "USD", Date: "2026-09-02", Provider: "aws"},
+	}
+	if err := testDB.SaveCostRecords(records); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestCostExportCSV(t *testing.T) {
+	records := seedRecords(t, useTestDB(t))
+	setOutput(t, "table", false)
+	out := filepath.Join(t.TempDir(), "costs.csv")
+
+	stdout, err := runCommand(t, costExportCmd(), "--format", "csv", "--out", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, "Exported 3 cost records") {
+		t.Errorf("output = %q, want the exported count", stdout)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) == 0 || strings.Join(rows[0], ",") != strings.Join(exportColumns, ",") {
+		t.Fatalf("header = %v, want %v", rows, exportColumns)
+	}
+	if len(rows)-1 != len(records) {
+		t.Errorf("got %d data rows, want %d", len(rows)-1, len(records))
+	}
+}
+
+func TestCostExportJSON(t *testing.T) {
+	records := seedRecords(t, useTestDB(t))
+	setOutput(t, "table", true)
+
+	stdout, err := runCommand(t, costExportCmd(), "--format", "json", "--start", "2026-09-02", "--end", "2026-09-30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported []exportRecord
+	if err := json.Unmarshal([]byte(stdout), &exported); err != nil {
+		t.Fatalf("export is not a JSON array: %v\n%s", err, stdout)
+	}
+	if len(exported) != len(records)-1 {
+		t.Errorf("got %d records from 2026-09-02, want %d", len(exported), len(records)-1)
+	}
+}
+
+func TestCostExportReportsWriteFailure(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full to fail writes")
+	}
+	seedRecords(t, useTestDB(t))
+	setOutput(t, "table", false)
+
+	stdout, err := runCommand(t, costExportCmd(), "--out", "/dev/full")
+	if err == nil {
+		t.Fatal("expected an error exporting to a full device")
+	}
+	if strings.Contains(stdout, "Exported") {
+		t.Errorf("output = %q, want no success message", stdout)
+	}
+}
+
+func TestConfigListJSON(t *testing.T) {
+	useTestDB(t)
+	c := &config.Config{}
+	c.Currency.Target = "EUR"
+	c.Storage.Path = "/var/lib/azguard/data.db"
+	useTestConfig(t, c)
+	setOutput(t, "json", false)
+
+	stdout, err := runCommand(t, configCmd(), "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var settings map[string]string
+	if err := json.Unmarshal([]byte(stdout), &settings); err != nil {
+		t.Fatalf("config list is not a JSON object: %v\n%s", err, stdout)
+	}
+	if settings["currency.target"] != "EUR" || settings["storage.path"] != c.Storage.Path {
+		t.Errorf("settings = %v, want currency.target EUR and the storage path", settings)
+	}
+	for _, key := range config.Keys() {
+		if _, ok := settings[key]; !ok {
+			t.Errorf("settings missing %s", key)
+		}
+	}
+}
+
+func TestConfigUnsetRemovesOverride(t *testing.T) {
+	testDB := useTestDB(t)
+	useTestConfig(t, &config.Config{})
+	setOutput(t, "table", false)
+
+	if _, err := runCommand(t, configCmd(), "set", "ollama.model", "llama3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCommand(t, configCmd(), "set", "currency.target", "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := runCommand(t, configCmd(), "unset", "ollama.model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, "ollama.model override removed") {
+		t.Errorf("output = %q, want a removal message", stdout)
+	}
+
+	overrides, err := testDB.GetAllConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := overrides["ollama.model"]; ok || overrides["currency.target"] != "EUR" {
+		t.Errorf("overrides = %v, want only currency.target left", overrides)
+	}
+
+	if _, err := runCommand(t, configCmd(), "unset", "no.such.key"); err == nil {
+		t.Error("expected an error unsetting an unknown key")
+	}
+}
+
+// readCSV parses CSV output, failing the test when it is malformed.
+func readCSV(t *testing.T, out string) [][]string {
+	t.Helper()
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not CSV: %v\n%s", err, out)
+	}
+	return rows
+}
+
+func TestPrintTrendAnalysisCSV(t *testing.T) {
+	setOutput(t, "csv", false)
+	trend := &cost.TrendAnalysis{
+		CurrentMonth:   120,
+		PreviousMonth:  100,
+		ChangePercent:  20,
+		Trend:          "increasing",
+		AverageMonthly: 110,
+		Projection:     131.456,
+		Currency:       "USD",
+	}
+
+	var err error
+	rows := readCSV(t, captureStdout(t, func() { err = printTrendAnalysis(trend) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"metric", "value"},
+		{"current_month", "120.00"},
+		{"previous_month", "100.00"},
+		{"change_percent", "20.00"},
+		{"trend", "increasing"},
+		{"average_monthly", "110.00"},
+		{"projection", "131.46"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("rows = %v, want %v", rows, want)
+	}
+	for i := range want {
+		if strings.Join(rows[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("row %d = %v, want %v", i, rows[i], want[i])
+		}
+	}
+}
+
+func TestPrintForecastCSV(t *testing.T) {
+	setOutput(t, "csv", false)
+	forecast := &cost.Forecast{NextMonth: 98.765, Confidence: "medium", Method: cost.ForecastLinear, Currency: "USD"}
+
+	var err error
+	rows := readCSV(t, captureStdout(t, func() { err = printForecast(forecast) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || strings.Join(rows[0], ",") != "next_month,confidence" || strings.Join(rows[1], ",") != "98.77,medium" {
+		t.Errorf("rows = %v, want a next_month,confidence header and one row", rows)
+	}
+}
+
+func TestCostFetchDryRunStoresNothing(t *testing.T) {
+	monthStart, _ := cost.GetCurrentMonthDateRange()
+	provider := &stubProvider{records: []storage.CostRecord{
+		{SubscriptionID: "sub-1", ServiceName: "Virtual Machines", Cost: 10, Currency: "USD", Date: monthStart, Provider: "azure"},
+		{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 2.5, Currency: "USD", Date: monthStart, Provider: "azure"},
+	}}
+	testDB := useTestProvider(t, provider)
+	setOutput(t, "table", false)
+
+	stdout, err := runCommand(t, costFetchCmd(), "--dry-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider queried %d times, want 1", provider.calls)
+	}
+	if !strings.Contains(stdout, "Dry run: 2 records totalling $12.50 would be stored") {
+		t.Errorf("output = %q, want the record count and total", stdout)
+	}
+
+	records, err := testDB.GetCostRecords(storage.CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("dry run stored %d records, want none", len(records))
+	}
+	state, err := testDB.GetFetchState("azure", "sub-1", cost.GranularityService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.LastDate != "" {
+		t.Errorf("dry run saved fetch state %+v", state)
+	}
+}
+
+func TestCostTopOrdersByCost(t *testing.T) {
+	testDB := useTestDB(t)
+	if err := testDB.SaveCostRecords([]storage.CostRecord{
+		{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 10, Currency: "USD", Date: "2026-09-01"},
+		{SubscriptionID: "sub-1", ServiceName: "Virtual Machines", Cost: 60, Currency: "USD", Date: "2026-09-01"},
+		{SubscriptionID: "sub-1", ServiceName: "Virtual Machines", Cost: 10, Currency: "USD", Date: "2026-09-02"},
+		{SubscriptionID: "sub-1", ServiceName: "Key Vault", Cost: 5, Currency: "USD", Date: "2026-09-02"},
+		{SubscriptionID: "sub-1", ServiceName: "Functions", Cost: 15, Currency: "USD", Date: "2026-09-03"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	setOutput(t, "json", false)
+
+	stdout, err := runCommand(t, costTopCmd(), "--n", "3", "--start", "2026-09-01", "--end", "2026-09-30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var services []cost.ServiceCost
+	if err := json.Unmarshal([]byte(stdout), &services); err != nil {
+		t.Fatalf("cost top is not a JSON array: %v\n%s", err, stdout)
+	}
+
+	// Percentages are shares of all spend, not just the services shown
+	want := []cost.ServiceCost{
+		{Service: "Virtual Machines", Cost: 70, Percent: 70},
+		{Service: "Functions", Cost: 15, Percent: 15},
+		{Service: "Storage", Cost: 10, Percent: 10},
+	}
+	if len(services) != len(want) {
+		t.Fatalf("services = %+v, want %+v", services, want)
+	}
+	for i := range want {
+		if services[i] != want[i] {
+			t.Errorf("service %d = %+v, want %+v", i, services[i], want[i])
+		}
+	}
+
+	if _, err := runCommand(t, costTopCmd(), "--n", "0"); err == nil {
+		t.Error("expected an error for --n 0")
+	}
+}
+
+func TestDoctorChecksEachSubscriptionOnce(t *testing.T) {
+	const primary = "00000000-0000-0000-0000-000000000001"
+	const extra = "00000000-0000-0000-0000-000000000002"
+	c := &config.Config{BillingCycleStartDay: 1}
+	c.Azure.AuthMethod = "cli"
+	c.Azure.SubscriptionID = primary
+	c.Azure.Subscriptions = []string{primary, extra, "", extra, "not-a-subscription"}
+	c.Currency.Target = "USD"
+	useTestConfig(t, c)
+	setOutput(t, "json", false)
+
+	prevProvider := azureTokenProvider
+	tokenRequests := 0
+	azureTokenProvider = func() (string, error) {
+		tokenRequests++
+		return "token", nil
+	}
+	t.Cleanup(func() { azureTokenProvider = prevProvider })
+
+	stdout, err := runCommand(t, doctorCmd())
+	if err == nil || !strings.Contains(err.Error(), "1 of 5 checks failed") {
+		t.Errorf("err = %v, want only the malformed subscription to fail", err)
+	}
+	var checks []doctorCheck
+	if err := json.Unmarshal([]byte(stdout), &checks); err != nil {
+		t.Fatalf("doctor output is not JSON: %v\n%s", err, stdout)
+	}
+
+	want := []string{
+		"configuration",
+		"azure credentials (cli)",
+		"azure subscription " + primary,
+		"azure subscription " + extra,
+		"azure subscription not-a-subscription",
+	}
+	if len(checks) != len(want) {
+		t.Fatalf("checks = %+v, want %v", checks, want)
+	}
+	for i, name := range want {
+		if checks[i].Name != name || checks[i].OK != (i < 4) {
+			t.Errorf("check %d = %+v, want %s", i, checks[i], name)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("requested %d tokens, want 1", tokenRequests)
+	}
+}
+
+func TestConfigureCurrencyAppliesTargetWithoutRates(t *testing.T) {
+	testDB := useTestDB(t)
+	if err := testDB.SaveCostRecords([]storage.CostRecord{
+		{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 10, Currency: "EUR", Date: "2026-09-01"},
+		{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 1200, Currency: "JPY", Date: "2026-09-02"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	filter := cost.CostFilter{StartDate: "2026-09-01", EndDate: "2026-09-30"}
+
+	c := &config.Config{}
+	c.Currency.Target = "eur"
+	svc := cost.NewService(testDB)
+	configureCurrency(svc, c)
+	if got := svc.TargetCurrency(); got != "EUR" {
+		t.Errorf("target currency = %s, want EUR", got)
+	}
+	// With no rates, mixed currencies can't be summed
+	if _, err := svc.GetCostSummary(filter); err == nil || !strings.Contains(err.Error(), "convert them to EUR") {
+		t.Errorf("err = %v, want a mixed currency error naming EUR", err)
+	}
+
+	c.Currency.Rates = map[string]float64{"JPY": 0.005}
+	svc = cost.NewService(testDB)
+	configureCurrency(svc, c)
+	summary, err := svc.GetCostSummary(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Currency != "EUR" || summary.TotalCost != 16 {
+		t.Errorf("summary = %v %s, want 16 EUR", summary.TotalCost, summary.Currency)
+	}
+}
+
+func TestCostImportCSVFeedsSummaries(t *testing.T) {
+	useTestDB(t)
+	setOutput(t, "table", false)
+	file := filepath.Join(t.TempDir(), "costs.csv")
+	if err := os.WriteFile(file, []byte(`Service_Name,cost,date,resource_group,currency
+Virtual Machines,12.50,2026-09-01,web-rg,usd
+Storage,2.25,2026-09-02,data-rg,
+Storage,not-a-number,2026-09-03,data-rg,USD
+,1,2026-09-03,,USD
+Functions,1,09/03/2026,,USD
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, err := runCommand(t, costImportCmd(), "--file", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, "Imported 2 cost records") || !strings.Contains(stdout, "(3 skipped)") {
+		t.Errorf("output = %q, want 2 imported and 3 skipped", stdout)
+	}
+
+	summary, err := costSvc.GetCostSummary(cost.CostFilter{StartDate: "2026-09-01", EndDate: "2026-09-30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalCost != 14.75 || summary.Currency != "USD" {
+		t.Errorf("total = %v %s, want 14.75 USD", summary.TotalCost, summary.Currency)
+	}
+	if summary.ByService["Virtual Machines"] != 12.5 || summary.ByResourceGroup["data-rg"] != 2.25 {
+		t.Errorf("summary = %+v, want the imported services and resource groups", summary)
+	}
+}
+
+func TestCostImportCSVRequiresColumns(t *testing.T) {
+	useTestDB(t)
+	file := filepath.Join(t.TempDir(), "costs.csv")
+	if err := os.WriteFile(file, []byte("service_name,date\nStorage,2026-09-01\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := runCommand(t, costImportCmd(), "--file", file)
+	if err == nil || !strings.Contains(err.Error(), `missing required column "cost"`) {
+		t.Errorf("err = %v, want a missing cost column error", err)
+	}
+}
+
+func TestCostCurrentNoFetchSkipsProvider(t *testing.T) {
+	monthStart, _ := cost.GetCurrentMonthDateRange()
+	provider := &stubProvider{}
+	testDB := useTestProvider(t, provider)
+	if err := testDB.SaveCostRecord(storage.CostRecord{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 4.5, Currency: "USD", Date: monthStart}); err != nil {
+		t.Fatal(err)
+	}
+	setOutput(t, "json", false)
+
+	stdout, err := runCommand(t, costCurrentCmd(), "--no-fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 0 {
+		t.Errorf("provider called %d times, want none", provider.calls)
+	}
+	var summary cost.CostSummary
+	if err := json.Unmarshal([]byte(stdout), &summary); err != nil {
+		t.Fatalf("cost current is not JSON: %v\n%s", err, stdout)
+	}
+	if summary.TotalCost != 4.5 || summary.Warning != "" {
+		t.Errorf("summary = %+v, want the stored 4.5 with no warning", summary)
+	}
+
+	if _, err := runCommand(t, costCurrentCmd()); err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls == 0 {
+		t.Error("without --no-fetch the provider was not called")
+	}
+}
+
+func TestNewLoggerFiltersByLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger("warn", "text", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("fetched costs", "records", 3)
+	logger.Warn("skipped import row", "reason", "bad date")
+
+	out := buf.String()
+	if strings.Contains(out, "fetched costs") {
+		t.Errorf("log = %q, want info dropped at warn level", out)
+	}
+	if !strings.Contains(out, `level=WARN msg="skipped import row" reason="bad date"`) {
+		t.Errorf("log = %q, want the warning", out)
+	}
+	if strings.Contains(out, "time=") {
+		t.Errorf("log = %q, want text logs without timestamps", out)
+	}
+}
+
+func TestNewLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger("debug", "json", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("fetched costs", "records", 3)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log is not JSON: %v\n%s", err, buf.String())
+	}
+	if entry["level"] != "DEBUG" || entry["msg"] != "fetched costs" || entry["records"] != 3.0 {
+		t.Errorf("entry = %v, want the debug message and its records", entry)
+	}
+}
+
+func TestNewLoggerRejectsUnknownSettings(t *testing.T) {
+	if _, err := newLogger("loud", "text", io.Discard); err == nil || !strings.Contains(err.Error(), "invalid log level") {
+		t.Errorf("err = %v, want an invalid log level error", err)
+	}
+	if _, err := newLogger("info", "xml", io.Discard); err == nil || !strings.Contains(err.Error(), "invalid log format") {
+		t.Errorf("err = %v, want an invalid log format error", err)
+	}
+}
+
+func TestQuietDropsConfirmations(t *testing.T) {
+	monthStart, _ := cost.GetCurrentMonthDateRange()
+	useTestProvider(t, &stubProvider{records: []storage.CostRecord{
+		{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 2, Currency: "USD", Date: monthStart, Provider: "azure"},
+	}})
+	out := filepath.Join(t.TempDir(), "costs.csv")
+
+	setOutput(t, "table", false)
+	stdout, err := runCommand(t, costFetchCmd())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, "Costs fetched and stored") {
+		t.Errorf("output = %q, want a confirmation without --quiet", stdout)
+	}
+
+	setOutput(t, "table", true)
+	for _, run := range []struct {
+		cmd  *cobra.Command
+		args []string
+	}{
+		{costFetchCmd(), nil},
+		{costExportCmd(), []string{"--out", out}},
+		{costImportCmd(), []string{"--file", out}},
+	} {
+		stdout, err := runCommand(t, run.cmd, run.args...)
+		if err != nil {
+			t.Fatalf("%s: %v", run.cmd.Name(), err)
+		}
+		if stdout != "" {
+			t.Errorf("%s printed %q with --quiet, want nothing", run.cmd.Name(), stdout)
+		}
+	}
+}
+
+// risingProvider bills 1.50 more for today on every cost query.
+type risingProvider struct {
+	stubProvider
+	queries int
+}
+
+func (p *risingProvider) QueryCostsByService(ctx context.Context, startDate, endDate string) ([]storage.CostRecord, error) {
+	p.queries++
+	return []storage.CostRecord{{
+		SubscriptionID: "sub-1",
+		ServiceName:    "Storage",
+		Cost:           1.5 * float64(p.queries),
+		Currency:       "USD",
+		Date:           time.Now().Format("2006-01-02"),
+		Provider:       "azure",
+	}}, nil
+}
+
+func TestWatchCostsRendersChangesOnTick(t *testing.T) {
+	useTestProvider(t, &risingProvider{})
+	setOutput(t, "table", false)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ticks := make(chan time.Time)
+
+	var err error
+	stdout := captureStdout(t, func() {
+		done := make(chan error)
+		go func() { done <- watchCosts(ctx, ticks, false, time.Minute) }()
+		// The watch only waits for a tick once it has rendered, so the
+		// second send returns after the tick-driven refresh is printed
+		ticks <- time.Now()
+		ticks <- time.Now()
+		cancel()
+		err = <-done
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renders := strings.Split(stdout, "\033[H\033[2J")
+	if len(renders) < 3 {
+		t.Fatalf("got %d renders, want at least 2:\n%s", len(renders)-1, stdout)
+	}
+	if strings.Contains(renders[1], "Changes since last refresh") {
+		t.Errorf("first render lists changes:\n%s", renders[1])
+	}
+	if !strings.Contains(renders[2], "Changes since last refresh:") || !strings.Contains(renders[2], "+$1.50") {
+		t.Errorf("second render = %q, want the total and Storage up $1.50", renders[2])
+	}
+	if !strings.Contains(renders[2], "refreshing every 1m0s") {
+		t.Errorf("second render = %q, want the refresh interval", renders[2])
+	}
+}
+
+func TestCostRecordsFilters(t *testing.T) {
+	seedRecords(t, useTestDB(t))
+	setOutput(t, "json", false)
+
+	for _, tt := range []struct {
+		args      []string
+		wantDates []string
+	}{
+		{nil, []string{"2026-09-02", "2026-09-02", "2026-09-01"}},
+		{[]string{"--service", "Storage"}, []string{"2026-09-02"}},
+		{[]string{"--provider", "aws"}, []string{"2026-09-02"}},
+		{[]string{"--start", "2026-09-02", "--end", "2026-09-30"}, []string{"2026-09-02", "2026-09-02"}},
+		{[]string{"--provider", "azure", "--end", "2026-09-01"}, []string{"2026-09-01"}},
+		{[]string{"--provider", "aws", "--service", "Storage"}, nil},
+		{[]string{"--limit", "1", "--offset", "2"}, []string{"2026-09-01"}},
+	} {
+		stdout, err := runCommand(t, costRecordsCmd(), tt.args...)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		var page costRecordsPage
+		if err := json.Unmarshal([]byte(stdout), &page); err != nil {
+			t.Fatalf("%v: output is not a records page: %v\n%s", tt.args, err, stdout)
+		}
+		var dates []string
+		for _, r := range page.Records {
+			dates = append(dates, r.Date)
+		}
+		if strings.Join(dates, ",") != strings.Join(tt.wantDates, ",") || page.Count != len(tt.wantDates) {
+			t.Errorf("%v: got %d records dated %v, want %v", tt.args, page.Count, dates, tt.wantDates)
+		}
+	}
+}
+
+func TestCostRecordsJSONEnvelope(t *testing.T) {
+	seedRecords(t, useTestDB(t))
+	setOutput(t, "json", false)
+
+	stdout, err := runCommand(t, costRecordsCmd(), "--service", "Virtual Machines", "--limit", "10", "--offset", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stdout), &envelope); err != nil {
+		t.Fatalf("output is not a JSON object: %v\n%s", err, stdout)
+	}
+	for _, key := range []string{"records", "count", "limit", "offset"} {
+		if _, ok := envelope[key]; !ok {
+			t.Errorf("envelope missing %q: %s", key, stdout)
+		}
+	}
+	if string(envelope["count"]) != "1" || string(envelope["limit"]) != "10" || string(envelope["offset"]) != "0" {
+		t.Errorf("envelope = %s, want count 1, limit 10 and offset 0", stdout)
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal(envelope["records"], &records); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"subscription_id": "sub-1",
+		"resource_group":  "web-rg",
+		"service_name":    "Virtual Machines",
+		"cost":            12.5,
+		"currency":        "USD",
+		"date":            "2026-09-01",
+		"provider":        "azure",
+	}
+	if len(records) != 1 || len(records[0]) != len(want) {
+		t.Fatalf("records = %v, want one record with %d fields", records, len(want))
+	}
+	for k, v := range want {
+		if records[0][k] != v {
+			t.Errorf("%s = %v, want %v", k, records[0][k], v)
+		}
+	}
+
+	// An empty page is still an envelope with an empty list
+	stdout, err = runCommand(t, costRecordsCmd(), "--service", "Nothing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, `"records": []`) || !strings.Contains(stdout, `"count": 0`) {
+		t.Errorf("empty page = %s, want an empty records list", stdout)
+	}
+}
+
+func TestPrintCostSummaryYAML(t *testing.T) {
+	setOutput(t, "yaml", false)
+	summary := &cost.CostSummary{
+		Period:          "2026-09-01 to 2026-09-30",
+		TotalCost:       15.75,
+		Currency:        "USD",
+		ByService:       map[string]float64{"Virtual Machines": 12.5, "Storage: Hot": 3.25},
+		ByResourceGroup: map[string]float64{"web-rg": 15.75},
+		Forecast:        &cost.Forecast{NextMonth: 20, Confidence: "medium", Method: cost.ForecastLinear, Currency: "USD"},
+	}
+
+	var err error
+	out := captureStdout(t, func() { err = printCostSummary(summary) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(out, "{}") {
+		t.Errorf("output = %q, want block-style YAML", out)
+	}
+	if !strings.HasPrefix(out, "period: 2026-09-01 to 2026-09-30\ntotal_cost: 15.75\n") {
+		t.Errorf("output = %q, want the JSON field names in order", out)
+	}
+
+	var decoded struct {
+		TotalCost float64            `yaml:"total_cost"`
+		ByService map[string]float64 `yaml:"by_service"`
+		Forecast  struct {
+			NextMonth float64 `yaml:"next_month"`
+			Method    string  `yaml:"method"`
+		} `yaml:"forecast"`
+	}
+	if err := yaml.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("output is not YAML: %v\n%s", err, out)
+	}
+	if decoded.TotalCost != 15.75 || decoded.ByService["Storage: Hot"] != 3.25 || decoded.Forecast.NextMonth != 20 || decoded.Forecast.Method != "linear" {
+		t.Errorf("decoded = %+v, want the summary's values", decoded)
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, format := range outputFormats {
+		if err := validateOutputFormat(format); err != nil {
+			t.Errorf("%s: %v", format, err)
+		}
+	}
+	err := validateOutputFormat("xml")
+	if err == nil || !strings.Contains(err.Error(), `invalid output format "xml"`) || !strings.Contains(err.Error(), "table, json, csv, markdown, yaml") {
+		t.Errorf("err = %v, want the bad format and the valid choices", err)
+	}
+}
+
+func TestInvalidOutputFailsBeforeOpeningStorage(t *testing.T) {
+	// Flag parsing sets the output globals; restore them afterwards
+	setOutput(t, "table", false)
+	prevDB := db
+	db = nil
+	t.Cleanup(func() { db = prevDB })
+
+	stdout, err := runCommand(t, newRootCmd(), "cost", "top", "--output", "xml")
+	if err == nil || !strings.Contains(err.Error(), `invalid output format "xml"`) {
+		t.Errorf("err = %v, want an invalid output format error", err)
+	}
+	if stdout != "" {
+		t.Errorf("output = %q, want nothing", stdout)
+	}
+	if db != nil {
+		t.Error("storage was opened for an invalid --output")
+	}
+}
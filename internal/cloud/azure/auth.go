@@ -3,12 +3,15 @@ package azure
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/exec"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,26 +24,45 @@ var TokenProviders = map[string]TokenProvider{
 }
 
 func GetCLIToken() (string, error) {
+	token, _, err := fetchCLIToken()
+	return token, err
+}
+
+func fetchCLIToken() (string, time.Time, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "az", "account", "get-access-token", "--resource", "https://management.azure.com", "--output", "json")
-	output, err := cmd.Output()
+	output, err := runAzureCLI(ctx, "account", "get-access-token", "--resource", "https://management.azure.com", "--output", "json")
 	if err != nil {
-		return "", fmt.Errorf("failed to get Azure CLI token: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to get Azure CLI token: %w", err)
 	}
 
 	var result struct {
-		AccessToken string `json:"accessToken"`
+		AccessToken string      `json:"accessToken"`
+		ExpiresOn   string      `json:"expiresOn"`
+		ExpiresOnTS json.Number `json:"expires_on"`
 	}
 	if err := json.Unmarshal(output, &result); err != nil {
-		return "", fmt.Errorf("failed to parse token: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to parse token: %w", err)
 	}
 
-	return result.AccessToken, nil
+	// Newer CLI versions report a Unix timestamp; older ones only a local time
+	var expiresAt time.Time
+	if ts, err := result.ExpiresOnTS.Int64(); err == nil {
+		expiresAt = time.Unix(ts, 0)
+	} else if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999", result.ExpiresOn, time.Local); err == nil {
+		expiresAt = t
+	}
+
+	return result.AccessToken, expiresAt, nil
 }
 
 func GetSPToken(tenantID, clientID, clientSecret string) (string, error) {
+	token, _, err := fetchSPToken(tenantID, clientID, clientSecret)
+	return token, err
+}
+
+func fetchSPToken(tenantID, clientID, clientSecret string) (string, time.Time, error) {
 	url := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", tenantID)
 
 	data := fmt.Sprintf(
@@ -50,99 +72,214 @@ func GetSPToken(tenantID, clientID, clientSecret string) (string, error) {
 
 	req, err := http.NewRequest("POST", url, strings.NewReader(data))
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("token request failed with status: %d", resp.StatusCode)
+		return "", time.Time{}, fmt.Errorf("token request failed with status: %d", resp.StatusCode)
 	}
 
 	var result struct {
-		AccessToken string `json:"access_token"`
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
-	return result.AccessToken, nil
+	return result.AccessToken, expiresIn(result.ExpiresIn), nil
 }
 
 func GetMIToken() (string, error) {
-	endpoint := os.Getenv("MSI_ENDPOINT")
-	if endpoint == "" {
-		endpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
-	}
+	token, _, err := fetchMIToken(os.Getenv("MSI_CLIENT_ID"))
+	return token, err
+}
 
-	clientID := os.Getenv("MSI_CLIENT_ID")
+// IMDSEndpoint is the instance metadata token endpoint reachable from Azure
+// VMs, scale sets, and other IaaS hosts.
+const IMDSEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+func fetchMIToken(clientID string) (string, time.Time, error) {
+	// App Service and Functions expose their own identity endpoint that
+	// requires a per-instance secret header instead of Metadata: true
+	endpoint := os.Getenv("IDENTITY_ENDPOINT")
+	identityHeader := os.Getenv("IDENTITY_HEADER")
+	apiVersion := "2019-08-01"
+	if endpoint == "" || identityHeader == "" {
+		endpoint = os.Getenv("MSI_ENDPOINT")
+		if endpoint == "" {
+			endpoint = IMDSEndpoint
+		}
+		identityHeader = ""
+		apiVersion = "2018-02-01"
+	}
 
-	url := fmt.Sprintf("%s?resource=https://management.azure.com&api-version=2018-02-01", endpoint)
+	url := fmt.Sprintf("%s?resource=https://management.azure.com&api-version=%s", endpoint, apiVersion)
 	if clientID != "" {
 		url += "&client_id=" + clientID
 	}
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
+	}
+	if identityHeader != "" {
+		req.Header.Set("X-IDENTITY-HEADER", identityHeader)
+	} else {
+		req.Header.Set("Metadata", "true")
 	}
-	req.Header.Set("Metadata", "true")
 
-	client := &http.Client{}
+	// The identity endpoint is link-local, so never route it through a
+	// proxy, and fail fast when it isn't there rather than hanging
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{Proxy: nil},
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, fmt.Errorf("managed identity endpoint %s is unreachable (managed_identity auth only works on Azure-hosted compute; use 'cli' or 'service_principal' elsewhere): %w", endpoint, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("managed identity token request failed with status: %d", resp.StatusCode)
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", time.Time{}, fmt.Errorf("managed identity token request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
 	}
 
+	// IMDS encodes expires_in as a string; json.Number accepts either form
 	var result struct {
-		AccessToken string `json:"access_token"`
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
-	return result.AccessToken, nil
+	return result.AccessToken, expiresIn(result.ExpiresIn), nil
 }
 
+// NewTokenProvider returns a provider for the given auth method. Tokens are
+// cached per method and identity and shared by every provider created in the
+// process, so repeated calls only reach Azure when the token nears expiry.
 func NewTokenProvider(authMethod string, config map[string]string) (TokenProvider, error) {
 	switch authMethod {
 	case "cli":
-		return GetCLIToken, nil
+		return cachedTokenProvider("cli", fetchCLIToken), nil
 	case "service_principal":
-		return func() (string, error) {
-			return GetSPToken(
+		key := "service_principal/" + config["tenant_id"] + "/" + config["client_id"]
+		return cachedTokenProvider(key, func() (string, time.Time, error) {
+			return fetchSPToken(
 				config["tenant_id"],
 				config["client_id"],
 				config["client_secret"],
 			)
-		}, nil
+		}), nil
 	case "managed_identity":
-		return GetMIToken, nil
+		// A configured client ID selects a user-assigned identity
+		clientID := config["client_id"]
+		if clientID == "" {
+			clientID = os.Getenv("MSI_CLIENT_ID")
+		}
+		return cachedTokenProvider("managed_identity/"+clientID, func() (string, time.Time, error) {
+			return fetchMIToken(clientID)
+		}), nil
 	default:
 		return nil, fmt.Errorf("unknown auth method: %s", authMethod)
 	}
 }
 
+// tokenRefreshWindow is how long before expiry a cached token is replaced.
+const tokenRefreshWindow = 5 * time.Minute
+
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+var tokenCache = struct {
+	sync.Mutex
+	entries map[string]cachedToken
+}{entries: make(map[string]cachedToken)}
+
+// cachedTokenProvider wraps fetch with the shared token cache. The lock is
+// held while fetching so concurrent callers wait for one request instead of
+// each starting their own. Tokens without a known expiry are not cached.
+func cachedTokenProvider(key string, fetch func() (string, time.Time, error)) TokenProvider {
+	return func() (string, error) {
+		tokenCache.Lock()
+		defer tokenCache.Unlock()
+
+		if cached, ok := tokenCache.entries[key]; ok && time.Until(cached.expiresAt) > tokenRefreshWindow {
+			return cached.token, nil
+		}
+
+		token, expiresAt, err := fetch()
+		if err != nil {
+			return "", err
+		}
+
+		if expiresAt.IsZero() {
+			delete(tokenCache.entries, key)
+		} else {
+			tokenCache.entries[key] = cachedToken{token: token, expiresAt: expiresAt}
+		}
+		return token, nil
+	}
+}
+
+func expiresIn(seconds json.Number) time.Time {
+	n, err := seconds.Int64()
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(n) * time.Second)
+}
+
+// lookPath finds executables; tests can replace it to simulate a missing az.
+var lookPath = exec.LookPath
+
+// runAzureCLI runs az with the given arguments and returns its stdout. It
+// returns ErrCLINotFound if az isn't installed, and wraps ErrAuth if the CLI
+// isn't logged in; other failures include what az printed to stderr.
+func runAzureCLI(ctx context.Context, args ...string) ([]byte, error) {
+	path, err := lookPath("az")
+	if err != nil {
+		return nil, ErrCLINotFound
+	}
+
+	output, err := exec.CommandContext(ctx, path, args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			stderr := strings.TrimSpace(string(exitErr.Stderr))
+			if strings.Contains(stderr, "az login") {
+				return nil, fmt.Errorf("%w: the Azure CLI is not logged in (run 'az login')", ErrAuth)
+			}
+			if stderr != "" {
+				return nil, fmt.Errorf("%w: %s", err, stderr)
+			}
+		}
+		return nil, err
+	}
+	return output, nil
+}
+
 // GetSubscriptionIDFromCLI retrieves the default subscription ID from Azure CLI
 func GetSubscriptionIDFromCLI() (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "az", "account", "show", "--output", "json")
-	output, err := cmd.Output()
+	output, err := runAzureCLI(ctx, "account", "show", "--output", "json")
 	if err != nil {
-		return "", fmt.Errorf("failed to get Azure account info: %w (run 'az login' first)", err)
+		return "", fmt.Errorf("failed to get Azure account info: %w", err)
 	}
 
 	var result struct {
@@ -0,0 +1,236 @@
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// stubLookPath makes az resolve to path, or be missing when path is empty.
+func stubLookPath(t *testing.T, path string) {
+	t.Helper()
+	prev := lookPath
+	lookPath = func(file string) (string, error) {
+		if file != "az" {
+			t.Errorf("looked up %q, want az", file)
+		}
+		if path == "" {
+			return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+		}
+		return path, nil
+	}
+	t.Cleanup(func() { lookPath = prev })
+}
+
+// fakeAzureCLI writes a script standing in for az.
+func fakeAzureCLI(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake az is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "az")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAzureCLIMissing(t *testing.T) {
+	stubLookPath(t, "")
+
+	_, err := GetSubscriptionIDFromCLI()
+	if !errors.Is(err, ErrCLINotFound) {
+		t.Fatalf("err = %v, want ErrCLINotFound", err)
+	}
+	if !strings.Contains(err.Error(), "install the Azure CLI") || strings.Contains(err.Error(), "executable file not found") {
+		t.Errorf("err = %q, want the install hint rather than the exec error", err)
+	}
+
+	if _, err := GetCLIToken(); !errors.Is(err, ErrCLINotFound) {
+		t.Errorf("GetCLIToken err = %v, want ErrCLINotFound", err)
+	}
+}
+
+func TestAzureCLIInstalled(t *testing.T) {
+	stubLookPath(t, fakeAzureCLI(t, `echo '{"id": "`+testSubscription+`"}'`))
+
+	id, err := GetSubscriptionIDFromCLI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != testSubscription {
+		t.Errorf("subscription = %q, want %q", id, testSubscription)
+	}
+}
+
+func TestAzureCLINotLoggedIn(t *testing.T) {
+	stubLookPath(t, fakeAzureCLI(t, `echo "ERROR: Please run 'az login' to setup account." >&2; exit 1`))
+
+	_, err := GetCLIToken()
+	if !errors.Is(err, ErrAuth) || errors.Is(err, ErrCLINotFound) {
+		t.Errorf("err = %v, want ErrAuth for a CLI that isn't logged in", err)
+	}
+}
+
+// fakeIdentityEndpoint serves managed identity tokens through MSI_ENDPOINT,
+// numbering each token so tests can tell a cached one from a refetch.
+func fakeIdentityEndpoint(t *testing.T, expiresIn string) *[]*http.Request {
+	t.Helper()
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": fmt.Sprintf("token-%d", len(requests)),
+			"expires_in":   expiresIn,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("IDENTITY_ENDPOINT", "")
+	t.Setenv("IDENTITY_HEADER", "")
+	t.Setenv("MSI_ENDPOINT", server.URL)
+	return &requests
+}
+
+// newTestMIProvider returns a managed identity provider with its own cache
+// entry, since the token cache is shared by the whole package.
+func newTestMIProvider(t *testing.T) TokenProvider {
+	t.Helper()
+	provider, err := NewTokenProvider("managed_identity", map[string]string{"client_id": t.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		tokenCache.Lock()
+		delete(tokenCache.entries, "managed_identity/"+t.Name())
+		tokenCache.Unlock()
+	})
+	return provider
+}
+
+func TestTokenProviderCachesUntilNearExpiry(t *testing.T) {
+	requests := fakeIdentityEndpoint(t, "3600")
+	provider := newTestMIProvider(t)
+
+	for i := 0; i < 3; i++ {
+		token, err := provider()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "token-1" {
+			t.Errorf("call %d got %q, want the cached token-1", i+1, token)
+		}
+	}
+	if len(*requests) != 1 {
+		t.Errorf("token endpoint hit %d times, want 1", len(*requests))
+	}
+}
+
+func TestTokenProviderRefetchesInsideRefreshWindow(t *testing.T) {
+	// A token expiring within tokenRefreshWindow is never worth reusing
+	requests := fakeIdentityEndpoint(t, "60")
+	provider := newTestMIProvider(t)
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*requests) != 2 {
+		t.Errorf("token endpoint hit %d times, want 2", len(*requests))
+	}
+}
+
+func TestTokenProviderSkipsCacheWithoutExpiry(t *testing.T) {
+	requests := fakeIdentityEndpoint(t, "0")
+	provider := newTestMIProvider(t)
+
+	first, err := provider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := provider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second || len(*requests) != 2 {
+		t.Errorf("got %q then %q from %d requests, want two fresh tokens", first, second, len(*requests))
+	}
+}
+
+func TestManagedIdentityUsesIMDSHeaders(t *testing.T) {
+	requests := fakeIdentityEndpoint(t, "3600")
+
+	token, _, err := fetchMIToken("user-assigned-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-1" || len(*requests) != 1 {
+		t.Fatalf("got %q from %d requests, want token-1 from one", token, len(*requests))
+	}
+
+	r := (*requests)[0]
+	query := r.URL.Query()
+	if r.Header.Get("Metadata") != "true" || r.Header.Get("X-IDENTITY-HEADER") != "" {
+		t.Errorf("headers = %v, want Metadata: true only", r.Header)
+	}
+	if query.Get("api-version") != "2018-02-01" || query.Get("resource") != "https://management.azure.com" || query.Get("client_id") != "user-assigned-id" {
+		t.Errorf("query = %v, want the IMDS api-version, ARM resource and client_id", query)
+	}
+}
+
+func TestManagedIdentityUsesAppServiceEndpoint(t *testing.T) {
+	requests := fakeIdentityEndpoint(t, "3600")
+	t.Setenv("IDENTITY_ENDPOINT", os.Getenv("MSI_ENDPOINT"))
+	t.Setenv("IDENTITY_HEADER", "instance-secret")
+	t.Setenv("MSI_ENDPOINT", "http://127.0.0.1:1")
+
+	if _, _, err := fetchMIToken(""); err != nil {
+		t.Fatal(err)
+	}
+
+	r := (*requests)[0]
+	if r.Header.Get("X-IDENTITY-HEADER") != "instance-secret" || r.Header.Get("Metadata") != "" {
+		t.Errorf("headers = %v, want the identity secret instead of Metadata", r.Header)
+	}
+	if got := r.URL.Query().Get("api-version"); got != "2019-08-01" {
+		t.Errorf("api-version = %q, want 2019-08-01", got)
+	}
+	if r.URL.Query().Has("client_id") {
+		t.Errorf("query = %v, want no client_id for the system identity", r.URL.Query())
+	}
+}
+
+func TestManagedIdentityReportsFailedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "identity not found", http.StatusBadRequest)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("IDENTITY_ENDPOINT", "")
+	t.Setenv("MSI_ENDPOINT", server.URL)
+
+	_, _, err := fetchMIToken("")
+	if err == nil || !strings.Contains(err.Error(), "status 400") || !strings.Contains(err.Error(), "identity not found") {
+		t.Errorf("err = %v, want the status and response body", err)
+	}
+}
+
+func TestManagedIdentityReportsUnreachableEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	t.Setenv("IDENTITY_ENDPOINT", "")
+	t.Setenv("MSI_ENDPOINT", server.URL)
+
+	_, _, err := fetchMIToken("")
+	if err == nil || !strings.Contains(err.Error(), "only works on Azure-hosted compute") {
+		t.Errorf("err = %v, want a hint that managed identity needs Azure compute", err)
+	}
+}
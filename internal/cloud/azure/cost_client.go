@@ -7,19 +7,31 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const (
 	AzureManagementURL = "https://management.azure.com"
 	CostManagementAPI  = "2023-03-01"
+
+	// ProviderName identifies Azure records in multi-cloud storage
+	ProviderName = "azure"
 )
 
+// maxQueryPages bounds how many result pages a single cost query follows. A
+// query with more pages fails rather than return part of its rows.
+const maxQueryPages = 50
+
 type CostClient struct {
 	SubscriptionID string
 	Token          string
 	TokenProvider  func() (string, error)
 	HTTPClient     *http.Client
+	// BaseURL is the management endpoint, AzureManagementURL unless a
+	// sovereign cloud or test server is used
+	BaseURL string
 }
 
 func NewCostClient(subscriptionID string, tokenProvider func() (string, error)) *CostClient {
@@ -27,6 +39,7 @@ func NewCostClient(subscriptionID string, tokenProvider func() (string, error))
 		SubscriptionID: subscriptionID,
 		TokenProvider:  tokenProvider,
 		HTTPClient:     &http.Client{Timeout: 60 * time.Second},
+		BaseURL:        AzureManagementURL,
 	}
 }
 
@@ -65,28 +78,24 @@ type Grouping struct {
 	Name string `json:"name"`
 }
 
+// CostQueryResponse is one page of query results. Azure returns them as a
+// table whose columns are the aggregation followed by the groupings.
 type CostQueryResponse struct {
-	Value []CostItem `json:"value"`
-}
-
-type CostItem struct {
-	ID         string         `json:"id"`
-	Name       NameProperty   `json:"name"`
-	Properties CostProperties `json:"properties"`
+	ID         string              `json:"id"`
+	Name       string              `json:"name"`
+	Properties CostQueryProperties `json:"properties"`
 }
 
-type NameProperty struct {
-	Value string `json:"value"`
+type CostQueryProperties struct {
+	// NextLink is where the next page of rows is fetched from, if any
+	NextLink string          `json:"nextLink"`
+	Columns  []QueryColumn   `json:"columns"`
+	Rows     [][]interface{} `json:"rows"`
 }
 
-type CostProperties struct {
-	Cost     float64            `json:"cost"`
-	Currency string             `json:"currency"`
-	UsageDate UsageDateProperty `json:"usageDate"`
-}
-
-type UsageDateProperty struct {
-	Value string `json:"value"`
+type QueryColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
 }
 
 type CostQueryResult struct {
@@ -101,26 +110,58 @@ type CostRecord struct {
 	Cost          float64
 	Currency      string
 	Date          string
+
+	ResourceID string
+	Meter      string
 }
 
 func (c *CostClient) QueryCosts(ctx context.Context, req CostQueryRequest) (*CostQueryResult, error) {
+	return c.query(ctx, "cost query", req)
+}
+
+// query runs a Cost Management query, following result pages, and parses
+// its rows.
+func (c *CostClient) query(ctx context.Context, operation string, req CostQueryRequest) (*CostQueryResult, error) {
 	if err := ValidateSubscriptionID(c.SubscriptionID); err != nil {
 		return nil, fmt.Errorf("invalid subscription ID: %w", err)
 	}
 
 	token, err := c.getToken()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get token: %w", err)
+		return nil, fmt.Errorf("%w: failed to get token: %w", ErrAuth, err)
 	}
 
-	url := fmt.Sprintf("%s/subscriptions/%s/providers/Microsoft.CostManagement/query?api-version=%s",
-		AzureManagementURL, c.SubscriptionID, CostManagementAPI)
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = AzureManagementURL
+	}
+	next := fmt.Sprintf("%s/subscriptions/%s/providers/Microsoft.CostManagement/query?api-version=%s",
+		baseURL, c.SubscriptionID, CostManagementAPI)
 
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 
+	result := &CostQueryResult{Currency: "USD"}
+	for page := 0; next != "" && page < maxQueryPages; page++ {
+		resp, err := c.post(ctx, operation, next, token, body)
+		if err != nil {
+			return nil, err
+		}
+		if err := result.addRows(resp.Properties); err != nil {
+			return nil, fmt.Errorf("%s: %w", operation, err)
+		}
+		next = resp.Properties.NextLink
+	}
+	// A partial result would replace the stored range with less than it had
+	if next != "" {
+		return nil, fmt.Errorf("cost query %s: more than %d result pages", operation, maxQueryPages)
+	}
+	return result, nil
+}
+
+func (c *CostClient) post(ctx context.Context, operation, url, token string, body []byte) (*CostQueryResponse, error) {
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
@@ -137,51 +178,160 @@ func (c *CostClient) QueryCosts(ctx context.Context, req CostQueryRequest) (*Cos
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("cost query failed with status %d: %s", resp.StatusCode, string(respBody))
+		return nil, newRequestError(operation, resp, respBody)
 	}
 
 	var result CostQueryResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
-
-	return c.parseResponse(result), nil
+	return &result, nil
 }
 
-func (c *CostClient) parseResponse(resp CostQueryResponse) *CostQueryResult {
-	var records []CostRecord
-	var totalCost float64
-	currency := "USD"
+// addRows parses a page of rows into records. Columns are found by name,
+// ignoring case; the cost column is named after the aggregated column, which
+// varies with the query type.
+func (r *CostQueryResult) addRows(props CostQueryProperties) error {
+	index := make(map[string]int, len(props.Columns))
+	for i, col := range props.Columns {
+		index[strings.ToLower(col.Name)] = i
+	}
+	column := func(names ...string) int {
+		for _, name := range names {
+			if i, ok := index[name]; ok {
+				return i
+			}
+		}
+		return -1
+	}
 
-	for _, item := range resp.Value {
-		properties := item.Properties
+	costCol := column("cost", "pretaxcost", "costtotal", "totalcost", "costusd")
+	if costCol < 0 && len(props.Rows) > 0 {
+		return fmt.Errorf("response has no cost column")
+	}
+	dateCol := column("usagedate")
+	serviceCol := column("servicename")
+	groupCol := column("resourcegroup", "resourcegroupname")
+	resourceCol := column("resourceid")
+	meterCol := column("meter")
+	currencyCol := column("currency")
+
+	for _, row := range props.Rows {
+		cost, ok := cell(row, costCol).(float64)
+		if !ok {
+			return fmt.Errorf("cost %v is not a number", cell(row, costCol))
+		}
 		record := CostRecord{
-			Cost:     properties.Cost,
-			Currency: properties.Currency,
-			Date:     properties.UsageDate.Value,
+			ServiceName:   stringCell(row, serviceCol),
+			ResourceGroup: stringCell(row, groupCol),
+			Cost:          cost,
+			Currency:      stringCell(row, currencyCol),
+			Date:          usageDate(cell(row, dateCol)),
+			ResourceID:    stringCell(row, resourceCol),
+			Meter:         stringCell(row, meterCol),
 		}
-
-		if item.Name.Value != "" {
-			record.ServiceName = item.Name.Value
+		if record.ResourceGroup == "" {
+			record.ResourceGroup = resourceGroupFromID(record.ResourceID)
 		}
 
-		totalCost += properties.Cost
-		if properties.Currency != "" {
-			currency = properties.Currency
+		r.TotalCost += cost
+		if record.Currency != "" {
+			r.Currency = record.Currency
 		}
+		r.Records = append(r.Records, record)
+	}
+	return nil
+}
 
-		records = append(records, record)
+func cell(row []interface{}, i int) interface{} {
+	if i < 0 || i >= len(row) {
+		return nil
 	}
+	return row[i]
+}
 
-	return &CostQueryResult{
-		Records:    records,
-		TotalCost: totalCost,
-		Currency:  currency,
+func stringCell(row []interface{}, i int) string {
+	s, _ := cell(row, i).(string)
+	return s
+}
+
+// usageDate formats a UsageDate cell as YYYY-MM-DD. Daily queries return it
+// as a number such as 20241001; some return an ISO 8601 timestamp instead.
+func usageDate(v interface{}) string {
+	var s string
+	switch d := v.(type) {
+	case float64:
+		s = strconv.FormatInt(int64(d), 10)
+	case string:
+		s = d
 	}
+	if len(s) == 8 && !strings.Contains(s, "-") {
+		return s[:4] + "-" + s[4:6] + "-" + s[6:]
+	}
+	if len(s) > 10 {
+		return s[:10]
+	}
+	return s
 }
 
+// QueryCostsByService returns daily costs per service and resource group.
+// Grouping by resource group as well keeps service-level records usable for
+// resource group budgets, summaries and trends without a resource-level
+// fetch.
 func (c *CostClient) QueryCostsByService(ctx context.Context, startDate, endDate string) (*CostQueryResult, error) {
-	req := CostQueryRequest{
+	return c.QueryCosts(ctx, dailyCostQuery(startDate, endDate, "ServiceName", "ResourceGroup"))
+}
+
+// QueryCostsByResource returns daily costs per service, resource and meter,
+// for chargeback at a finer grain than QueryCostsByService. A query can only
+// group by two dimensions, so costs are fetched per resource and meter, and
+// a second query finds the service each meter belongs to. A meter billed
+// under several services is attributed to the one it cost most in.
+func (c *CostClient) QueryCostsByResource(ctx context.Context, startDate, endDate string) (*CostQueryResult, error) {
+	result, err := c.QueryCosts(ctx, dailyCostQuery(startDate, endDate, "ResourceId", "Meter"))
+	if err != nil {
+		return nil, err
+	}
+	meters, err := c.QueryCosts(ctx, dailyCostQuery(startDate, endDate, "ServiceName", "Meter"))
+	if err != nil {
+		return nil, err
+	}
+
+	type meterService struct {
+		name string
+		cost float64
+	}
+	totals := make(map[string]map[string]float64)
+	for _, r := range meters.Records {
+		if totals[r.Meter] == nil {
+			totals[r.Meter] = make(map[string]float64)
+		}
+		totals[r.Meter][r.ServiceName] += r.Cost
+	}
+	services := make(map[string]meterService)
+	for meter, byService := range totals {
+		for name, cost := range byService {
+			best, ok := services[meter]
+			if !ok || cost > best.cost || (cost == best.cost && name < best.name) {
+				services[meter] = meterService{name: name, cost: cost}
+			}
+		}
+	}
+
+	for i := range result.Records {
+		result.Records[i].ServiceName = services[result.Records[i].Meter].name
+	}
+	return result, nil
+}
+
+// dailyCostQuery builds an actual cost query for the date range, with one
+// row per day and value of the dimensions.
+func dailyCostQuery(startDate, endDate string, dimensions ...string) CostQueryRequest {
+	grouping := make([]Grouping, 0, len(dimensions))
+	for _, d := range dimensions {
+		grouping = append(grouping, Grouping{Type: "Dimension", Name: d})
+	}
+	return CostQueryRequest{
 		Type:      "ActualCost",
 		Timeframe: "Custom",
 		TimePeriod: &TimePeriod{
@@ -196,13 +346,22 @@ func (c *CostClient) QueryCostsByService(ctx context.Context, startDate, endDate
 					Function: "Sum",
 				},
 			},
-			Grouping: []Grouping{
-				{Type: "Dimension", Name: "ServiceName"},
-			},
+			Grouping: grouping,
 		},
 	}
+}
 
-	return c.QueryCosts(ctx, req)
+// resourceGroupFromID extracts the resource group from an Azure resource ID
+// such as /subscriptions/{id}/resourceGroups/{rg}/providers/..., returning ""
+// if there isn't one. Azure is inconsistent about the segment's case.
+func resourceGroupFromID(resourceID string) string {
+	parts := strings.Split(resourceID, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if strings.EqualFold(parts[i], "resourceGroups") {
+			return parts[i+1]
+		}
+	}
+	return ""
 }
 
 func (c *CostClient) QueryCostsByResourceGroup(ctx context.Context, startDate, endDate string) (*CostQueryResult, error) {
@@ -231,18 +390,6 @@ func (c *CostClient) QueryCostsByResourceGroup(ctx context.Context, startDate, e
 }
 
 func (c *CostClient) GetForecast(ctx context.Context, granularity string) (*CostQueryResult, error) {
-	if err := ValidateSubscriptionID(c.SubscriptionID); err != nil {
-		return nil, fmt.Errorf("invalid subscription ID: %w", err)
-	}
-
-	token, err := c.getToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get token: %w", err)
-	}
-
-	url := fmt.Sprintf("%s/subscriptions/%s/providers/Microsoft.CostManagement/query?api-version=%s",
-		AzureManagementURL, c.SubscriptionID, CostManagementAPI)
-
 	forecastReq := CostQueryRequest{
 		Type:      "Forecast",
 		Timeframe: "BillingMonthToDate",
@@ -257,50 +404,12 @@ func (c *CostClient) GetForecast(ctx context.Context, granularity string) (*Cost
 		},
 	}
 
-	body, err := json.Marshal(forecastReq)
-	if err != nil {
-		return nil, err
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
-	if err != nil {
-		return nil, err
-	}
-
-	httpReq.Header.Set("Authorization", "Bearer "+token)
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.HTTPClient.Do(httpReq)
+	result, err := c.query(ctx, "forecast request", forecastReq)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("forecast request failed with status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var result CostQueryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	var total float64
-	var currency string
-	for _, item := range result.Value {
-		total += item.Properties.Cost
-		if item.Properties.Currency != "" {
-			currency = item.Properties.Currency
-		}
-	}
-
-	if currency == "" {
-		currency = "USD"
-	}
-
 	return &CostQueryResult{
-		TotalCost: total,
-		Currency:  currency,
+		TotalCost: result.TotalCost,
+		Currency:  result.Currency,
 	}, nil
 }
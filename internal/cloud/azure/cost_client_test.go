@@ -0,0 +1,192 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testSubscription = "00000000-0000-0000-0000-000000000001"
+
+// queryHandler answers cost queries with the rows listed for their groupings,
+// splitting each answer into pages of one row linked by nextLink.
+func queryHandler(t *testing.T, columns []QueryColumn, rows map[string][][]interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var req CostQueryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding query: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Dataset.Grouping) > 2 {
+			t.Errorf("query groups by %d dimensions, Azure allows 2", len(req.Dataset.Grouping))
+		}
+		var key string
+		for _, g := range req.Dataset.Grouping {
+			key += g.Name + ","
+		}
+
+		all := rows[key]
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		props := CostQueryProperties{Columns: columns}
+		if page < len(all) {
+			props.Rows = all[page : page+1]
+		}
+		if page+1 < len(all) {
+			props.NextLink = "http://" + r.Host + r.URL.Path + "?api-version=" + CostManagementAPI + "&page=" + strconv.Itoa(page+1)
+		}
+		_ = json.NewEncoder(w).Encode(CostQueryResponse{Properties: props})
+	}
+}
+
+func newTestClient(t *testing.T, handler http.Handler) *CostClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := NewCostClient(testSubscription, nil)
+	client.Token = "token"
+	client.BaseURL = server.URL
+	return client
+}
+
+func TestQueryCostsByServiceParsesRows(t *testing.T) {
+	columns := []QueryColumn{
+		{Name: "Cost", Type: "Number"},
+		{Name: "UsageDate", Type: "Number"},
+		{Name: "ServiceName", Type: "String"},
+		{Name: "ResourceGroup", Type: "String"},
+		{Name: "Currency", Type: "String"},
+	}
+	client := newTestClient(t, queryHandler(t, columns, map[string][][]interface{}{
+		"ServiceName,ResourceGroup,": {
+			{12.5, 20261001, "Storage", "data-rg", "EUR"},
+			{3.25, 20261002, "Virtual Machines", "web-rg", "EUR"},
+		},
+	}))
+
+	result, err := client.QueryCostsByService(context.Background(), "2026-10-01", "2026-10-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CostRecord{
+		{ServiceName: "Storage", ResourceGroup: "data-rg", Cost: 12.5, Currency: "EUR", Date: "2026-10-01"},
+		{ServiceName: "Virtual Machines", ResourceGroup: "web-rg", Cost: 3.25, Currency: "EUR", Date: "2026-10-02"},
+	}
+	if len(result.Records) != len(want) {
+		t.Fatalf("got %d records across pages, want %d", len(result.Records), len(want))
+	}
+	for i := range want {
+		if result.Records[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, result.Records[i], want[i])
+		}
+	}
+	if result.TotalCost != 15.75 || result.Currency != "EUR" {
+		t.Errorf("total = %.2f %s, want 15.75 EUR", result.TotalCost, result.Currency)
+	}
+}
+
+func TestQueryCostsByResourceFindsEachMetersService(t *testing.T) {
+	vm := "/subscriptions/" + testSubscription + "/resourceGroups/web-rg/providers/Microsoft.Compute/virtualMachines/web-1"
+	columns := []QueryColumn{
+		{Name: "PreTaxCost", Type: "Number"},
+		{Name: "UsageDate", Type: "Number"},
+		{Name: "ResourceId", Type: "String"},
+		{Name: "ServiceName", Type: "String"},
+		{Name: "Meter", Type: "String"},
+		{Name: "Currency", Type: "String"},
+	}
+	client := newTestClient(t, queryHandler(t, columns, map[string][][]interface{}{
+		"ResourceId,Meter,": {
+			{4.0, 20261001, vm, nil, "D2s v3", "USD"},
+			{0.5, 20261001, vm, nil, "P10 Disks", "USD"},
+		},
+		"ServiceName,Meter,": {
+			{4.0, 20261001, nil, "Virtual Machines", "D2s v3", "USD"},
+			{0.5, 20261001, nil, "Storage", "P10 Disks", "USD"},
+		},
+	}))
+
+	result, err := client.QueryCostsByResource(context.Background(), "2026-10-01", "2026-10-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CostRecord{
+		{ServiceName: "Virtual Machines", ResourceGroup: "web-rg", Cost: 4, Currency: "USD", Date: "2026-10-01", ResourceID: vm, Meter: "D2s v3"},
+		{ServiceName: "Storage", ResourceGroup: "web-rg", Cost: 0.5, Currency: "USD", Date: "2026-10-01", ResourceID: vm, Meter: "P10 Disks"},
+	}
+	if len(result.Records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(result.Records), len(want))
+	}
+	for i := range want {
+		if result.Records[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, result.Records[i], want[i])
+		}
+	}
+}
+
+func TestQueryCostsRejectsResponseWithoutCostColumn(t *testing.T) {
+	client := newTestClient(t, queryHandler(t, []QueryColumn{{Name: "UsageDate"}}, map[string][][]interface{}{
+		"ServiceName,ResourceGroup,": {{20261001}},
+	}))
+
+	_, err := client.QueryCostsByService(context.Background(), "2026-10-01", "2026-10-31")
+	if err == nil || !strings.Contains(err.Error(), "no cost column") {
+		t.Errorf("err = %v, want a missing cost column error", err)
+	}
+}
+
+func TestQueryCostsFailsPastPageLimit(t *testing.T) {
+	pages := 0
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		_ = json.NewEncoder(w).Encode(CostQueryResponse{Properties: CostQueryProperties{
+			Columns:  []QueryColumn{{Name: "Cost"}, {Name: "UsageDate"}, {Name: "ServiceName"}},
+			Rows:     [][]interface{}{{1.0, 20261001, "Storage"}},
+			NextLink: "http://" + r.Host + r.URL.Path + "?page=" + strconv.Itoa(pages),
+		}})
+	}))
+
+	result, err := client.QueryCostsByService(context.Background(), "2026-10-01", "2026-10-31")
+	if err == nil || !strings.Contains(err.Error(), "more than 50 result pages") {
+		t.Errorf("err = %v, want a page limit error", err)
+	}
+	if result != nil {
+		t.Errorf("got a partial result of %d records", len(result.Records))
+	}
+	if pages != maxQueryPages {
+		t.Errorf("followed %d pages, want %d", pages, maxQueryPages)
+	}
+}
+
+func TestQueryCostsKeepsTokenError(t *testing.T) {
+	client := NewCostClient(testSubscription, func() (string, error) { return "", ErrCLINotFound })
+
+	_, err := client.QueryCostsByService(context.Background(), "2026-10-01", "2026-10-31")
+	if !errors.Is(err, ErrAuth) || !errors.Is(err, ErrCLINotFound) {
+		t.Errorf("err = %v, want both ErrAuth and ErrCLINotFound", err)
+	}
+}
+
+func TestUsageDate(t *testing.T) {
+	for _, tt := range []struct {
+		in   interface{}
+		want string
+	}{
+		{float64(20261001), "2026-10-01"},
+		{"20261001", "2026-10-01"},
+		{"2026-10-01T00:00:00", "2026-10-01"},
+		{nil, ""},
+	} {
+		if got := usageDate(tt.in); got != tt.want {
+			t.Errorf("usageDate(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Sentinel errors for the kinds of failure callers treat differently. A
+// RequestError unwraps to one of them, so errors.Is(err, ErrAuth) works on
+// anything returned by the cost client.
+var (
+	ErrAuth        = errors.New("azure authentication failed")
+	ErrRateLimited = errors.New("azure request rate limited")
+	ErrBadRequest  = errors.New("azure rejected the request")
+
+	ErrCLINotFound = errors.New("az not found in PATH; install the Azure CLI (https://aka.ms/installazurecli) or set azure.auth_method to service_principal or managed_identity")
+)
+
+// RequestError is a non-2xx response from an Azure API.
+type RequestError struct {
+	Operation  string
+	StatusCode int
+	Body       string
+	// RetryAfter is the server's requested back-off for rate-limited
+	// requests, or zero if none was given
+	RetryAfter time.Duration
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s failed with status %d: %s", e.Operation, e.StatusCode, e.Body)
+}
+
+func (e *RequestError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrAuth
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 400 && e.StatusCode < 500:
+		return ErrBadRequest
+	default:
+		return nil
+	}
+}
+
+func newRequestError(operation string, resp *http.Response, body []byte) *RequestError {
+	err := &RequestError{
+		Operation:  operation,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+	}
+	if secs, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+		err.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return err
+}
+
+// IsTransient reports whether err suggests the API itself is unavailable or
+// overloaded (a network failure, a 5xx response or rate limiting) rather
+// than a problem with the request or credentials.
+func IsTransient(err error) bool {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.StatusCode >= 500 || reqErr.StatusCode == http.StatusTooManyRequests
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && !errors.Is(err, context.Canceled)
+}
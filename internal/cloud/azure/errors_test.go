@@ -0,0 +1,60 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRequestErrorKinds(t *testing.T) {
+	for _, tt := range []struct {
+		status    int
+		want      error
+		transient bool
+	}{
+		{http.StatusUnauthorized, ErrAuth, false},
+		{http.StatusForbidden, ErrAuth, false},
+		{http.StatusTooManyRequests, ErrRateLimited, true},
+		{http.StatusBadRequest, ErrBadRequest, false},
+		{http.StatusNotFound, ErrBadRequest, false},
+		{http.StatusInternalServerError, nil, true},
+		{http.StatusServiceUnavailable, nil, true},
+	} {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "7")
+			http.Error(w, "nope", tt.status)
+		}))
+
+		_, err := client.QueryCostsByService(context.Background(), "2026-10-01", "2026-10-31")
+		var reqErr *RequestError
+		if !errors.As(err, &reqErr) {
+			t.Fatalf("status %d: err = %v, want a *RequestError", tt.status, err)
+		}
+		if reqErr.StatusCode != tt.status || reqErr.RetryAfter != 7*time.Second {
+			t.Errorf("status %d: got %+v, want the status and a 7s Retry-After", tt.status, reqErr)
+		}
+		for _, sentinel := range []error{ErrAuth, ErrRateLimited, ErrBadRequest} {
+			if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+				t.Errorf("status %d: errors.Is(%v) = %v", tt.status, sentinel, got)
+			}
+		}
+		if got := IsTransient(err); got != tt.transient {
+			t.Errorf("status %d: IsTransient = %v, want %v", tt.status, got, tt.transient)
+		}
+	}
+}
+
+func TestQueryCostsReportsMissingCLI(t *testing.T) {
+	stubLookPath(t, "")
+	client := NewCostClient(testSubscription, GetCLIToken)
+
+	_, err := client.QueryCostsByService(context.Background(), "2026-10-01", "2026-10-31")
+	if !errors.Is(err, ErrCLINotFound) || !errors.Is(err, ErrAuth) {
+		t.Errorf("err = %v, want ErrCLINotFound wrapped as an auth failure", err)
+	}
+	if IsTransient(err) {
+		t.Error("a missing CLI should not be treated as transient")
+	}
+}
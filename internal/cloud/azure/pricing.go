@@ -0,0 +1,216 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RetailPricesURL is the public Azure Retail Prices API. It needs no
+// authentication.
+const RetailPricesURL = "https://prices.azure.com/api/retail/prices"
+
+// maxPricePages bounds how many result pages a single lookup follows.
+const maxPricePages = 10
+
+// HoursPerMonth is the average number of hours in a month, as Azure uses in
+// its pricing calculator.
+const HoursPerMonth = 730
+
+// serviceNames maps compact spellings such as "VirtualMachines" to the
+// serviceName values the Retail Prices API filters on.
+var serviceNames = map[string]string{
+	"virtualmachines": "Virtual Machines",
+	"vm":              "Virtual Machines",
+	"storage":         "Storage",
+	"sqldatabase":     "SQL Database",
+	"appservice":      "Azure App Service",
+	"functions":       "Functions",
+	"kubernetes":      "Azure Kubernetes Service",
+	"cosmosdb":        "Azure Cosmos DB",
+}
+
+// PricingClient looks up list prices from the Azure Retail Prices API.
+type PricingClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewPricingClient() *PricingClient {
+	return &PricingClient{
+		BaseURL:    RetailPricesURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// PriceQuery selects retail prices. Service accepts either the API's
+// service name ("Virtual Machines") or a compact form ("VirtualMachines").
+type PriceQuery struct {
+	Service  string
+	SKU      string
+	Region   string
+	Currency string
+}
+
+type RetailPrice struct {
+	CurrencyCode  string  `json:"currencyCode"`
+	RetailPrice   float64 `json:"retailPrice"`
+	UnitPrice     float64 `json:"unitPrice"`
+	ArmRegionName string  `json:"armRegionName"`
+	ProductName   string  `json:"productName"`
+	SkuName       string  `json:"skuName"`
+	ArmSkuName    string  `json:"armSkuName"`
+	MeterName     string  `json:"meterName"`
+	ServiceName   string  `json:"serviceName"`
+	UnitOfMeasure string  `json:"unitOfMeasure"`
+	Type          string  `json:"type"`
+}
+
+type retailPricesResponse struct {
+	Items        []RetailPrice `json:"Items"`
+	NextPageLink string        `json:"NextPageLink"`
+}
+
+// Estimate is the projected cost of running a SKU for a number of hours.
+type Estimate struct {
+	Service     string  `json:"service"`
+	SKU         string  `json:"sku"`
+	Region      string  `json:"region"`
+	Product     string  `json:"product"`
+	Meter       string  `json:"meter"`
+	HourlyPrice float64 `json:"hourly_price"`
+	Hours       float64 `json:"hours"`
+	Cost        float64 `json:"cost"`
+	Currency    string  `json:"currency"`
+}
+
+// GetRetailPrices returns the pay-as-you-go prices matching the query,
+// following result pages.
+func (c *PricingClient) GetRetailPrices(ctx context.Context, q PriceQuery) ([]RetailPrice, error) {
+	var conditions []string
+	if q.Service != "" {
+		conditions = append(conditions, fmt.Sprintf("serviceName eq '%s'", odataString(PricingServiceName(q.Service))))
+	}
+	if q.SKU != "" {
+		conditions = append(conditions, fmt.Sprintf("armSkuName eq '%s'", odataString(q.SKU)))
+	}
+	if q.Region != "" {
+		conditions = append(conditions, fmt.Sprintf("armRegionName eq '%s'", odataString(strings.ToLower(q.Region))))
+	}
+	conditions = append(conditions, "priceType eq 'Consumption'")
+
+	params := url.Values{}
+	params.Set("$filter", strings.Join(conditions, " and "))
+	if q.Currency != "" {
+		params.Set("currencyCode", strings.ToUpper(q.Currency))
+	}
+	next := c.BaseURL + "?" + params.Encode()
+
+	var prices []RetailPrice
+	for page := 0; next != "" && page < maxPricePages; page++ {
+		result, err := c.getPage(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, result.Items...)
+		next = result.NextPageLink
+	}
+	return prices, nil
+}
+
+func (c *PricingClient) getPage(ctx context.Context, pageURL string) (*retailPricesResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newRequestError("retail price lookup", resp, body)
+	}
+
+	var result retailPricesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// EstimateCost prices running the queried SKU for the given hours, using its
+// regular hourly rate. Spot and low priority meters are ignored, and Linux
+// rates are preferred over Windows ones when both are listed.
+func (c *PricingClient) EstimateCost(ctx context.Context, q PriceQuery, hours float64) (*Estimate, error) {
+	prices, err := c.GetRetailPrices(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	price, ok := selectHourlyPrice(prices)
+	if !ok {
+		return nil, fmt.Errorf("no hourly retail price found for %s %s in %s", PricingServiceName(q.Service), q.SKU, q.Region)
+	}
+
+	return &Estimate{
+		Service:     price.ServiceName,
+		SKU:         price.ArmSkuName,
+		Region:      price.ArmRegionName,
+		Product:     price.ProductName,
+		Meter:       price.MeterName,
+		HourlyPrice: price.RetailPrice,
+		Hours:       hours,
+		Cost:        math.Round(price.RetailPrice*hours*100) / 100,
+		Currency:    price.CurrencyCode,
+	}, nil
+}
+
+func selectHourlyPrice(prices []RetailPrice) (RetailPrice, bool) {
+	var best RetailPrice
+	found, bestIsWindows := false, false
+	for _, p := range prices {
+		if p.UnitOfMeasure != "1 Hour" || p.Type != "Consumption" {
+			continue
+		}
+		name := p.SkuName + " " + p.MeterName
+		if strings.Contains(name, "Spot") || strings.Contains(name, "Low Priority") {
+			continue
+		}
+
+		windows := strings.Contains(p.ProductName, "Windows")
+		switch {
+		case !found:
+		case bestIsWindows && !windows:
+		case bestIsWindows == windows && p.RetailPrice < best.RetailPrice:
+		default:
+			continue
+		}
+		best, found, bestIsWindows = p, true, windows
+	}
+	return best, found
+}
+
+// PricingServiceName resolves a compact service name to the one the Retail
+// Prices API uses, returning other names unchanged.
+func PricingServiceName(service string) string {
+	key := strings.ToLower(strings.ReplaceAll(service, " ", ""))
+	if name, ok := serviceNames[key]; ok {
+		return name
+	}
+	return service
+}
+
+// odataString escapes a value for a single-quoted OData string literal.
+func odataString(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
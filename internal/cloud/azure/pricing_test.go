@@ -0,0 +1,105 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakePricing serves prices in pages of one item, recording each $filter.
+func fakePricing(t *testing.T, prices []RetailPrice) (*PricingClient, *[]string) {
+	t.Helper()
+	var filters []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filters = append(filters, r.URL.Query().Get("$filter"))
+		page := 0
+		if r.URL.Query().Get("page") == "1" {
+			page = 1
+		}
+		resp := retailPricesResponse{}
+		if page < len(prices) {
+			resp.Items = prices[page : page+1]
+		}
+		if page == 0 && len(prices) > 1 {
+			resp.NextPageLink = "http://" + r.Host + r.URL.Path + "?page=1"
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewPricingClient()
+	client.BaseURL = server.URL
+	return client, &filters
+}
+
+func TestEstimateCostUsesLinuxHourlyRate(t *testing.T) {
+	client, filters := fakePricing(t, []RetailPrice{
+		{CurrencyCode: "USD", RetailPrice: 0.188, ArmRegionName: "eastus", ProductName: "Virtual Machines DSv3 Series Windows",
+			SkuName: "D2s v3", ArmSkuName: "Standard_D2s_v3", MeterName: "D2s v3", ServiceName: "Virtual Machines", UnitOfMeasure: "1 Hour", Type: "Consumption"},
+		{CurrencyCode: "USD", RetailPrice: 0.096, ArmRegionName: "eastus", ProductName: "Virtual Machines DSv3 Series",
+			SkuName: "D2s v3", ArmSkuName: "Standard_D2s_v3", MeterName: "D2s v3", ServiceName: "Virtual Machines", UnitOfMeasure: "1 Hour", Type: "Consumption"},
+	})
+
+	estimate, err := client.EstimateCost(context.Background(), PriceQuery{Service: "VirtualMachines", SKU: "Standard_D2s_v3", Region: "EastUS"}, HoursPerMonth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.HourlyPrice != 0.096 || estimate.Cost != 70.08 || estimate.Currency != "USD" {
+		t.Errorf("estimate = %+v, want 0.096/hour costing 70.08 USD", estimate)
+	}
+
+	want := "serviceName eq 'Virtual Machines' and armSkuName eq 'Standard_D2s_v3' and armRegionName eq 'eastus' and priceType eq 'Consumption'"
+	if len(*filters) != 2 || (*filters)[0] != want {
+		t.Errorf("filters = %q, want two pages queried with %q", *filters, want)
+	}
+}
+
+func TestEstimateCostSkipsSpotAndUnknownSKUs(t *testing.T) {
+	client, _ := fakePricing(t, []RetailPrice{
+		{CurrencyCode: "USD", RetailPrice: 0.02, ProductName: "Virtual Machines DSv3 Series",
+			SkuName: "D2s v3 Spot", MeterName: "D2s v3 Spot", UnitOfMeasure: "1 Hour", Type: "Consumption"},
+	})
+
+	_, err := client.EstimateCost(context.Background(), PriceQuery{Service: "VirtualMachines", SKU: "Standard_D2s_v3", Region: "eastus"}, HoursPerMonth)
+	if err == nil || !strings.Contains(err.Error(), "no hourly retail price") {
+		t.Errorf("err = %v, want no hourly price found", err)
+	}
+}
+
+func TestGetRetailPricesReportsHTTPErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad filter", http.StatusBadRequest)
+	}))
+	defer server.Close()
+	client := NewPricingClient()
+	client.BaseURL = server.URL
+
+	_, err := client.GetRetailPrices(context.Background(), PriceQuery{SKU: "x"})
+	var reqErr *RequestError
+	if err == nil || !strings.Contains(err.Error(), "bad filter") {
+		t.Fatalf("err = %v, want the API's error", err)
+	}
+	if !errors.As(err, &reqErr) || reqErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("err = %#v, want a 400 RequestError", err)
+	}
+}
+
+func TestPricingServiceName(t *testing.T) {
+	for in, want := range map[string]string{
+		"VirtualMachines":  "Virtual Machines",
+		"virtual machines": "Virtual Machines",
+		"Storage":          "Storage",
+		"Azure Firewall":   "Azure Firewall",
+	} {
+		if got := PricingServiceName(in); got != want {
+			t.Errorf("PricingServiceName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := odataString("O'Brien"); got != "O''Brien" {
+		t.Errorf("odataString = %q", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/azguard/azguard/internal/cloud/azure"
 	"github.com/spf13/viper"
@@ -11,11 +12,24 @@ import (
 
 type Config struct {
 	Ollama    OllamaConfig    `mapstructure:"ollama"`
-	Anthropic AnthropicConfig  `mapstructure:"anthropic"`
+	Anthropic AnthropicConfig `mapstructure:"anthropic"`
 	Azure     AzureConfig     `mapstructure:"azure"`
 	AWS       AWSConfig       `mapstructure:"aws"`
 	GCP       GCPConfig       `mapstructure:"gcp"`
 	Storage   StorageConfig   `mapstructure:"storage"`
+	Currency  CurrencyConfig  `mapstructure:"currency"`
+	Alerts    AlertsConfig    `mapstructure:"alerts"`
+	Email     EmailConfig     `mapstructure:"email"`
+	Cache     CacheConfig     `mapstructure:"cache"`
+
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+
+	// FreeTierPath overrides where free tier limits are loaded from
+	FreeTierPath string `mapstructure:"free_tier_path"`
+
+	// BillingCycleStartDay is the day of the month invoices start on; days
+	// past the end of a short month fall on its last day
+	BillingCycleStartDay int `mapstructure:"billing_cycle_start_day"`
 }
 
 type OllamaConfig struct {
@@ -31,9 +45,11 @@ type AnthropicConfig struct {
 type AzureConfig struct {
 	AuthMethod     string `mapstructure:"auth_method"`
 	SubscriptionID string `mapstructure:"subscription_id"`
-	TenantID       string `mapstructure:"tenant_id"`
-	ClientID       string `mapstructure:"client_id"`
-	ClientSecret   string `mapstructure:"client_secret"`
+	// Subscriptions are fetched in addition to SubscriptionID
+	Subscriptions []string `mapstructure:"subscriptions"`
+	TenantID      string   `mapstructure:"tenant_id"`
+	ClientID      string   `mapstructure:"client_id"`
+	ClientSecret  string   `mapstructure:"client_secret"`
 }
 
 type AWSConfig struct {
@@ -51,22 +67,68 @@ type StorageConfig struct {
 	Path string `mapstructure:"path"`
 }
 
+// CurrencyConfig controls how costs billed in different currencies are
+// combined. Each rate is the value of one unit of that currency in Target.
+type CurrencyConfig struct {
+	Target string             `mapstructure:"target"`
+	Rates  map[string]float64 `mapstructure:"rates"`
+}
+
+// AlertsConfig controls where triggered budget alerts are sent.
+type AlertsConfig struct {
+	WebhookURL   string `mapstructure:"webhook_url"`
+	SlackWebhook string `mapstructure:"slack_webhook"`
+}
+
+// EmailConfig is the SMTP server used to mail reports. TLS selects implicit
+// TLS; otherwise STARTTLS is used when the server supports it.
+type EmailConfig struct {
+	SMTPHost string `mapstructure:"smtp_host"`
+	SMTPPort int    `mapstructure:"smtp_port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+	TLS      bool   `mapstructure:"tls"`
+}
+
+// CacheConfig controls how long computed trends and forecasts are reused.
+// A TTL of 0 disables the cache.
+type CacheConfig struct {
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// CircuitBreakerConfig stops calling a cloud API after FailureThreshold
+// consecutive network, 5xx or rate-limit failures, for Cooldown. A threshold
+// of 0 disables the breaker.
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `mapstructure:"failure_threshold"`
+	Cooldown         time.Duration `mapstructure:"cooldown"`
+}
+
 var cfg *Config
 
+// Load reads the configuration. An empty configPath searches ~/.azguard and
+// the working directory for config.yaml; otherwise configPath names the file
+// to read, and it must exist.
 func Load(configPath string) (*Config, error) {
 	home, _ := os.UserHomeDir()
-	
+
 	viper.SetConfigType("yaml")
 	viper.SetConfigName("config")
 	viper.AddConfigPath(home + "/.azguard")
 	viper.AddConfigPath(".")
-	viper.AddConfigPath(configPath)
 
 	viper.SetDefault("ollama.base_url", "http://localhost:11434")
 	viper.SetDefault("ollama.model", "codellama")
 	viper.SetDefault("anthropic.model", "claude-3-sonnet-20240229")
 	viper.SetDefault("azure.auth_method", "cli")
 	viper.SetDefault("storage.path", "~/.azguard/data.db")
+	viper.SetDefault("currency.target", "USD")
+	viper.SetDefault("email.smtp_port", 587)
+	viper.SetDefault("cache.ttl", "5m")
+	viper.SetDefault("circuit_breaker.failure_threshold", 3)
+	viper.SetDefault("circuit_breaker.cooldown", "1m")
+	viper.SetDefault("billing_cycle_start_day", 1)
 
 	envFile := os.Getenv("AGENT_ENV_FILE")
 	if envFile != "" {
@@ -82,6 +144,17 @@ func Load(configPath string) (*Config, error) {
 	if err := viper.BindEnv("azure.client_secret", "AZURE_CLIENT_SECRET"); err != nil {
 		return nil, err
 	}
+	if err := viper.BindEnv("email.password", "SMTP_PASSWORD"); err != nil {
+		return nil, err
+	}
+
+	if configPath != "" {
+		configPath = expandHome(configPath)
+		if _, err := os.Stat(configPath); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", configPath, err)
+		}
+		viper.SetConfigFile(configPath)
+	}
 
 	if err := viper.ReadInConfig(); err != nil {
 		_, ok := err.(viper.ConfigFileNotFoundError)
@@ -90,12 +163,17 @@ func Load(configPath string) (*Config, error) {
 		}
 	}
 
-	if err := viper.Unmarshal(&cfg); err != nil {
+	// UnmarshalExact rejects keys that don't map to a field, so a typo in
+	// the config file is reported instead of silently ignored. Decoding into
+	// a fresh Config keeps maps such as currency.rates from an earlier Load
+	cfg = &Config{}
+	if err := viper.UnmarshalExact(cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
 	cfg.Storage.Path = expandHome(cfg.Storage.Path)
 	cfg.Ollama.BaseURL = expandHome(cfg.Ollama.BaseURL)
+	cfg.FreeTierPath = expandHome(cfg.FreeTierPath)
 
 	// Auto-detect subscription ID from Azure CLI if not set or invalid
 	if cfg.Azure.SubscriptionID == "" {
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/azguard/azguard/internal/storage"
+)
+
+// loadTestConfig loads a config file holding contents. The subscription is
+// set so Load doesn't ask the Azure CLI for one.
+func loadTestConfig(t *testing.T, contents string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	contents += "azure:\n  subscription_id: 00000000-0000-0000-0000-000000000001\n"
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return c
+}
+
+func TestDatabaseOverridesFileValue(t *testing.T) {
+	c := loadTestConfig(t, "ollama:\n  model: file-model\ncurrency:\n  target: EUR\n")
+
+	db, err := storage.New(filepath.Join(t.TempDir(), "azguard.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.SetConfig("ollama.model", "db-model"); err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := db.GetAllConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ApplyOverrides(overrides)
+
+	for key, want := range map[string]string{
+		"ollama.model":    "db-model",               // set in the file and the database
+		"currency.target": "EUR",                    // only in the file
+		"ollama.base_url": "http://localhost:11434", // only the default
+	} {
+		got, err := c.Value(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if got := c.Settings()["ollama.model"]; got != "db-model" {
+		t.Errorf("Settings()[ollama.model] = %q, want the database value", got)
+	}
+}
+
+func TestApplyOverridesIgnoresUnknownKeys(t *testing.T) {
+	c := &Config{}
+	c.ApplyOverrides(map[string]string{"retired.key": "x", "currency.target": "gbp"})
+	if c.Currency.Target != "GBP" {
+		t.Errorf("currency.target = %q, want GBP", c.Currency.Target)
+	}
+}
+
+func TestCanonicalKey(t *testing.T) {
+	for in, want := range map[string]string{
+		"subscription":    "azure.subscription_id",
+		"auth":            "azure.auth_method",
+		"tenant_id":       "azure.tenant_id",
+		" Ollama.Model ":  "ollama.model",
+		"currency.target": "currency.target",
+	} {
+		got, err := CanonicalKey(in)
+		if err != nil || got != want {
+			t.Errorf("CanonicalKey(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := CanonicalKey("azure.client_secret"); err == nil {
+		t.Error("secrets must not be settable with config set")
+	}
+}
+
+func TestValidateValue(t *testing.T) {
+	if err := ValidateValue("azure.auth_method", "password"); err == nil {
+		t.Error("expected an error for an unknown auth method")
+	}
+	if err := ValidateValue("currency.target", "EURO"); err == nil {
+		t.Error("expected an error for a four-letter currency code")
+	}
+	if err := ValidateValue("azure.auth_method", "managed_identity"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadPrefersExplicitConfigFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeFile := func(path, contents string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const subscription = "azure:\n  subscription_id: 00000000-0000-0000-0000-000000000001\n"
+	writeFile(filepath.Join(home, ".azguard", "config.yaml"), "ollama:\n  model: home-model\n"+subscription)
+	writeFile(filepath.Join(home, "custom.yaml"), "ollama:\n  model: custom-model\n"+subscription)
+
+	// A leading ~ is expanded like the other configured paths
+	c, err := Load("~/custom.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Ollama.Model != "custom-model" {
+		t.Errorf("ollama.model = %q, want the explicit file's value", c.Ollama.Model)
+	}
+	if c.Ollama.BaseURL != "http://localhost:11434" {
+		t.Errorf("ollama.base_url = %q, want the default", c.Ollama.BaseURL)
+	}
+
+	if _, err := Load(filepath.Join(home, "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing explicit config file")
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	contents := "azure:\n  subscription_id: 00000000-0000-0000-0000-000000000001\n  auth_mehtod: cli\n"
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "auth_mehtod") {
+		t.Errorf("err = %v, want the misspelled key reported", err)
+	}
+}
+
+func TestValidateReportsEveryMissingField(t *testing.T) {
+	c := loadTestConfig(t, "email:\n  smtp_host: smtp.example.com\n")
+	c.Currency.Rates = map[string]float64{"EUR": 0}
+	c.Azure.AuthMethod = "service_principal"
+	c.Azure.ClientID = "client"
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		"azure.tenant_id is required",
+		"azure.client_secret is required",
+		"currency.rates.EUR must be positive",
+		"email.from is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want it to mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "azure.client_id") {
+		t.Errorf("err = %v, want no complaint about the client ID that is set", err)
+	}
+}
+
+func TestValidateAcceptsDefaults(t *testing.T) {
+	if err := loadTestConfig(t, "").Validate(); err != nil {
+		t.Errorf("default configuration is invalid: %v", err)
+	}
+}
+
+func TestLoadStartsFromAFreshConfig(t *testing.T) {
+	loadTestConfig(t, "currency:\n  rates:\n    EUR: 1.1\n")
+	if c := loadTestConfig(t, ""); len(c.Currency.Rates) != 0 {
+		t.Errorf("rates = %v, want none carried over from the previous load", c.Currency.Rates)
+	}
+}
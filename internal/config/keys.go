@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// settings maps each key that can be overridden with 'azguard config set' to
+// the field it controls. Secrets and storage.path are deliberately absent:
+// secrets belong in the config file or environment, and the database path
+// can't be read from the database itself.
+var settings = map[string]func(c *Config) *string{
+	"ollama.base_url":       func(c *Config) *string { return &c.Ollama.BaseURL },
+	"ollama.model":          func(c *Config) *string { return &c.Ollama.Model },
+	"anthropic.model":       func(c *Config) *string { return &c.Anthropic.Model },
+	"azure.auth_method":     func(c *Config) *string { return &c.Azure.AuthMethod },
+	"azure.subscription_id": func(c *Config) *string { return &c.Azure.SubscriptionID },
+	"azure.tenant_id":       func(c *Config) *string { return &c.Azure.TenantID },
+	"azure.client_id":       func(c *Config) *string { return &c.Azure.ClientID },
+	"aws.region":            func(c *Config) *string { return &c.AWS.Region },
+	"gcp.project_id":        func(c *Config) *string { return &c.GCP.ProjectID },
+	"currency.target":       func(c *Config) *string { return &c.Currency.Target },
+	"free_tier_path":        func(c *Config) *string { return &c.FreeTierPath },
+	"alerts.webhook_url":    func(c *Config) *string { return &c.Alerts.WebhookURL },
+	"alerts.slack_webhook":  func(c *Config) *string { return &c.Alerts.SlackWebhook },
+}
+
+// keyAliases keeps the short names 'config set' has always accepted.
+var keyAliases = map[string]string{
+	"subscription": "azure.subscription_id",
+	"auth":         "azure.auth_method",
+}
+
+// Keys returns the canonical setting keys in sorted order.
+func Keys() []string {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CanonicalKey resolves a user-supplied key, including the legacy aliases and
+// bare azure field names such as "tenant_id", to its canonical form.
+func CanonicalKey(key string) (string, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if alias, ok := keyAliases[key]; ok {
+		return alias, nil
+	}
+	if _, ok := settings[key]; ok {
+		return key, nil
+	}
+	if _, ok := settings["azure."+key]; ok {
+		return "azure." + key, nil
+	}
+	return "", fmt.Errorf("unknown config key %q (valid keys: %s)", key, strings.Join(Keys(), ", "))
+}
+
+// ValidateValue rejects values a setting can never accept.
+func ValidateValue(key, value string) error {
+	switch key {
+	case "azure.auth_method":
+		switch value {
+		case "cli", "service_principal", "managed_identity":
+		default:
+			return fmt.Errorf("invalid auth method %q (use cli, service_principal, or managed_identity)", value)
+		}
+	case "currency.target":
+		if len(value) != 3 {
+			return fmt.Errorf("invalid currency code %q", value)
+		}
+	}
+	return nil
+}
+
+// Value returns the effective value of a canonical key.
+func (c *Config) Value(key string) (string, error) {
+	field, ok := settings[key]
+	if !ok {
+		return "", fmt.Errorf("unknown config key %q", key)
+	}
+	return *field(c), nil
+}
+
+// Settings returns every canonical key with its effective value.
+func (c *Config) Settings() map[string]string {
+	result := make(map[string]string, len(settings))
+	for k, field := range settings {
+		result[k] = *field(c)
+	}
+	return result
+}
+
+// ApplyOverrides sets fields from stored overrides, which take precedence
+// over the config file and defaults. Keys that are no longer recognised are
+// ignored so old databases keep working.
+func (c *Config) ApplyOverrides(overrides map[string]string) {
+	for k, v := range overrides {
+		field, ok := settings[k]
+		if !ok {
+			continue
+		}
+		if k == "currency.target" {
+			v = strings.ToUpper(v)
+		}
+		if k == "free_tier_path" {
+			v = expandHome(v)
+		}
+		*field(c) = v
+	}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Validate reports settings that are present but unusable, such as a
+// service principal without its credentials. Every problem found is
+// returned, joined into one error.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if err := ValidateValue("azure.auth_method", c.Azure.AuthMethod); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Azure.AuthMethod == "service_principal" {
+		for _, f := range []struct{ key, value string }{
+			{"azure.tenant_id", c.Azure.TenantID},
+			{"azure.client_id", c.Azure.ClientID},
+			{"azure.client_secret", c.Azure.ClientSecret},
+		} {
+			if f.value == "" {
+				errs = append(errs, fmt.Errorf("%s is required when azure.auth_method is service_principal", f.key))
+			}
+		}
+	}
+
+	if err := ValidateValue("currency.target", c.Currency.Target); err != nil {
+		errs = append(errs, err)
+	}
+	codes := make([]string, 0, len(c.Currency.Rates))
+	for code := range c.Currency.Rates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if c.Currency.Rates[code] <= 0 {
+			errs = append(errs, fmt.Errorf("currency.rates.%s must be positive", code))
+		}
+	}
+
+	for _, f := range []struct{ key, url string }{
+		{"alerts.webhook_url", c.Alerts.WebhookURL},
+		{"alerts.slack_webhook", c.Alerts.SlackWebhook},
+	} {
+		if f.url != "" && !strings.HasPrefix(f.url, "http://") && !strings.HasPrefix(f.url, "https://") {
+			errs = append(errs, fmt.Errorf("%s must be an http or https URL", f.key))
+		}
+	}
+
+	if c.Email.SMTPHost != "" {
+		if c.Email.From == "" {
+			errs = append(errs, fmt.Errorf("email.from is required when email.smtp_host is set"))
+		}
+		if c.Email.SMTPPort <= 0 || c.Email.SMTPPort > 65535 {
+			errs = append(errs, fmt.Errorf("email.smtp_port %d is not a valid port", c.Email.SMTPPort))
+		}
+	}
+
+	if c.Cache.TTL < 0 {
+		errs = append(errs, fmt.Errorf("cache.ttl must not be negative"))
+	}
+	if c.CircuitBreaker.FailureThreshold < 0 {
+		errs = append(errs, fmt.Errorf("circuit_breaker.failure_threshold must not be negative"))
+	}
+	if c.CircuitBreaker.Cooldown < 0 {
+		errs = append(errs, fmt.Errorf("circuit_breaker.cooldown must not be negative"))
+	}
+	if c.BillingCycleStartDay < 1 || c.BillingCycleStartDay > 31 {
+		errs = append(errs, fmt.Errorf("billing_cycle_start_day %d must be between 1 and 31", c.BillingCycleStartDay))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,98 @@
+package cost
+
+import (
+	"context"
+	"time"
+
+	"github.com/azguard/azguard/internal/notify"
+	"github.com/azguard/azguard/internal/storage"
+)
+
+type AlertState string
+
+const (
+	AlertOK        AlertState = "ok"
+	AlertWarning   AlertState = "warning"
+	AlertTriggered AlertState = "triggered"
+)
+
+// EvaluateAlert reports whether spend has reached the alert's threshold, or
+// crossed its warning fraction of the threshold without reaching it.
+func EvaluateAlert(alert storage.Alert, spend float64) AlertState {
+	warningThreshold := alert.WarningThreshold
+	if warningThreshold <= 0 {
+		warningThreshold = storage.DefaultWarningThreshold
+	}
+
+	switch {
+	case spend >= alert.Threshold:
+		return AlertTriggered
+	case spend >= warningThreshold*alert.Threshold:
+		return AlertWarning
+	default:
+		return AlertOK
+	}
+}
+
+// AlertSpend returns the portion of the summary an alert is scoped to: a
+// single service, a single resource group, or the total.
+func AlertSpend(alert storage.Alert, summary *CostSummary) float64 {
+	switch {
+	case alert.ServiceName != "":
+		return summary.ByService[alert.ServiceName]
+	case alert.ResourceGroup != "":
+		return summary.ByResourceGroup[alert.ResourceGroup]
+	default:
+		return summary.TotalCost
+	}
+}
+
+// AlertResult is the outcome of checking one enabled alert.
+type AlertResult struct {
+	Alert storage.Alert
+	Spend float64
+	State AlertState
+	// Notified is set when this check sent a notification; NotifyErr holds
+	// the error when sending one failed
+	Notified  bool
+	NotifyErr error
+}
+
+// CheckAlerts evaluates every enabled alert against the summary. When a
+// notifier is set, an alert is notified only on the check where it first
+// becomes triggered; it must drop below its threshold before it notifies
+// again. A failed notification is retried on the next check.
+func (s *Service) CheckAlerts(ctx context.Context, summary *CostSummary) ([]AlertResult, error) {
+	alerts, err := s.db.GetAlerts()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []AlertResult
+	for _, a := range alerts {
+		if !a.Enabled {
+			continue
+		}
+
+		result := AlertResult{Alert: a, Spend: AlertSpend(a, summary)}
+		result.State = EvaluateAlert(a, result.Spend)
+
+		if result.State == AlertTriggered && a.LastState != string(AlertTriggered) && s.notifier != nil {
+			result.NotifyErr = s.notifier.Notify(ctx, notify.Event{
+				Alert:       a.Name,
+				Threshold:   a.Threshold,
+				CurrentCost: result.Spend,
+				Timestamp:   time.Now().UTC(),
+			})
+			result.Notified = result.NotifyErr == nil
+		}
+
+		if result.NotifyErr == nil && a.LastState != string(result.State) {
+			if err := s.db.SetAlertLastState(a.Name, string(result.State)); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,217 @@
+package cost
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/azguard/azguard/internal/notify"
+	"github.com/azguard/azguard/internal/storage"
+)
+
+func TestCheckAlertsNotifiesOncePerCrossing(t *testing.T) {
+	var deliveries atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries.Add(1)
+	}))
+	defer server.Close()
+
+	db := newTestDB(t)
+	if err := db.SaveAlert(storage.Alert{Name: "budget-50", Threshold: 50, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(db)
+	svc.SetNotifier(notify.NewWebhookNotifier(server.URL))
+	ctx := context.Background()
+
+	for i, step := range []struct {
+		spend float64
+		want  int32
+	}{
+		{40, 0}, // warning only
+		{60, 1}, // crosses the threshold
+		{70, 1}, // still over it
+		{30, 1}, // back under
+		{55, 2}, // crosses again
+	} {
+		if _, err := svc.CheckAlerts(ctx, &CostSummary{TotalCost: step.spend}); err != nil {
+			t.Fatal(err)
+		}
+		if got := deliveries.Load(); got != step.want {
+			t.Fatalf("check %d at %.2f: %d deliveries, want %d", i+1, step.spend, got, step.want)
+		}
+	}
+}
+
+func TestCheckAlertsRetriesFailedNotification(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	db := newTestDB(t)
+	if err := db.SaveAlert(storage.Alert{Name: "budget-50", Threshold: 50, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(db)
+	svc.SetNotifier(notify.NewWebhookNotifier(server.URL))
+	summary := &CostSummary{TotalCost: 60}
+
+	results, err := svc.CheckAlerts(context.Background(), summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].NotifyErr == nil || results[0].Notified {
+		t.Fatalf("first check = %+v, want a failed notification", results[0])
+	}
+
+	results, err = svc.CheckAlerts(context.Background(), summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Notified || attempts.Load() != 2 {
+		t.Errorf("second check notified = %v after %d attempts, want a retried delivery", results[0].Notified, attempts.Load())
+	}
+}
+
+func TestCheckAlertsWarnsBelowThreshold(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.SaveAlert(storage.Alert{Name: "budget-100", Threshold: 100, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(db)
+
+	for _, tt := range []struct {
+		spend float64
+		want  AlertState
+	}{
+		{79.99, AlertOK},
+		{80, AlertWarning},
+		{85, AlertWarning},
+		{100, AlertTriggered},
+	} {
+		results, err := svc.CheckAlerts(context.Background(), &CostSummary{TotalCost: tt.spend})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].State != tt.want {
+			t.Errorf("spend %.2f: results = %+v, want %s", tt.spend, results, tt.want)
+		}
+	}
+}
+
+func TestEvaluateAlertUsesConfiguredWarningFraction(t *testing.T) {
+	alert := storage.Alert{Threshold: 100, WarningThreshold: 0.9}
+	if got := EvaluateAlert(alert, 85); got != AlertOK {
+		t.Errorf("85%% of a budget warning at 90%% = %s, want ok", got)
+	}
+	if got := EvaluateAlert(alert, 95); got != AlertWarning {
+		t.Errorf("95%% of a budget warning at 90%% = %s, want warning", got)
+	}
+}
+
+func TestScopedAlertsOnlyCountTheirSpend(t *testing.T) {
+	db := newTestDB(t)
+	vm := costRecord("2026-10-01", "VirtualMachines", "USD", 60)
+	vm.ResourceGroup = "web-rg"
+	blob := costRecord("2026-10-01", "Storage", "USD", 30)
+	blob.ResourceGroup = "data-rg"
+	if err := db.SaveCostRecords([]storage.CostRecord{vm, blob}); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []storage.Alert{
+		{Name: "vm-50", Threshold: 50, Enabled: true, ServiceName: "VirtualMachines"},
+		{Name: "storage-50", Threshold: 50, Enabled: true, ServiceName: "Storage"},
+		{Name: "data-rg-50", Threshold: 50, Enabled: true, ResourceGroup: "data-rg"},
+		{Name: "web-rg-50", Threshold: 50, Enabled: true, ResourceGroup: "web-rg"},
+		{Name: "total-50", Threshold: 50, Enabled: true},
+	} {
+		if err := db.SaveAlert(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := NewService(db)
+
+	summary, err := svc.GetCostSummary(CostFilter{StartDate: "2026-10-01", EndDate: "2026-10-31"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := svc.CheckAlerts(context.Background(), summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		spend float64
+		state AlertState
+	}{
+		"vm-50":      {60, AlertTriggered},
+		"storage-50": {30, AlertOK},
+		"data-rg-50": {30, AlertOK},
+		"web-rg-50":  {60, AlertTriggered},
+		"total-50":   {90, AlertTriggered},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for _, r := range results {
+		w := want[r.Alert.Name]
+		if r.Spend != w.spend || r.State != w.state {
+			t.Errorf("%s: spend %.2f %s, want %.2f %s", r.Alert.Name, r.Spend, r.State, w.spend, w.state)
+		}
+	}
+}
+
+func TestCheckAlertsSkipsDisabledAlerts(t *testing.T) {
+	db := newTestDB(t)
+	for _, name := range []string{"budget-50", "budget-60"} {
+		if err := db.SaveAlert(storage.Alert{Name: name, Threshold: 50, Enabled: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := NewService(db)
+	summary := &CostSummary{TotalCost: 70}
+
+	checked := func() []string {
+		t.Helper()
+		results, err := svc.CheckAlerts(context.Background(), summary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, r := range results {
+			names = append(names, r.Alert.Name)
+		}
+		return names
+	}
+
+	if err := db.SetAlertEnabled("budget-60", false); err != nil {
+		t.Fatal(err)
+	}
+	alert, err := db.GetAlertByName("budget-60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert.Enabled {
+		t.Error("disabled alert is still enabled when read back")
+	}
+	if got := checked(); len(got) != 1 || got[0] != "budget-50" {
+		t.Errorf("checked %v, want only budget-50", got)
+	}
+
+	if err := db.SetAlertEnabled("budget-60", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := checked(); len(got) != 2 {
+		t.Errorf("checked %v after re-enabling, want both alerts", got)
+	}
+
+	if err := db.SetAlertEnabled("missing", false); err == nil {
+		t.Error("expected an error disabling a missing alert")
+	}
+}
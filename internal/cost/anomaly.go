@@ -0,0 +1,87 @@
+package cost
+
+import (
+	"math"
+	"sort"
+)
+
+// anomalyWindowDays is the number of preceding days with recorded costs used
+// as the baseline for each day, and anomalyMinHistory the fewest needed before
+// a day is judged. anomalyMinStddev floors the standard deviation at that
+// fraction of the mean, so on steady spend a cent's rise isn't a spike.
+const (
+	anomalyWindowDays = 7
+	anomalyMinHistory = 3
+	anomalyMinStddev  = 0.1
+)
+
+type Anomaly struct {
+	Date      string  `json:"date"`
+	Cost      float64 `json:"cost"`
+	Expected  float64 `json:"expected"`
+	Deviation float64 `json:"deviation"`
+	Currency  string  `json:"currency"`
+}
+
+// DetectAnomalies flags days whose total spend exceeds the trailing mean by
+// more than stddevThreshold standard deviations. Deviation is the amount by
+// which the day exceeded the expected (mean) spend.
+func (s *Service) DetectAnomalies(filter CostFilter, stddevThreshold float64) ([]Anomaly, error) {
+	records, err := s.db.GetCostRecords(filter.storageFilter(""))
+	if err != nil {
+		return nil, err
+	}
+
+	costs, currency, err := s.recordCosts(records)
+	if err != nil {
+		return nil, err
+	}
+
+	daily := make(map[string]float64)
+	for i, r := range records {
+		daily[r.Date] += costs[i]
+	}
+
+	dates := make([]string, 0, len(daily))
+	for date := range daily {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	var anomalies []Anomaly
+	for i, date := range dates {
+		start := i - anomalyWindowDays
+		if start < 0 {
+			start = 0
+		}
+		window := dates[start:i]
+		if len(window) < anomalyMinHistory {
+			continue
+		}
+
+		var sum float64
+		for _, d := range window {
+			sum += daily[d]
+		}
+		mean := sum / float64(len(window))
+
+		var variance float64
+		for _, d := range window {
+			variance += (daily[d] - mean) * (daily[d] - mean)
+		}
+		stddev := math.Max(math.Sqrt(variance/float64(len(window))), anomalyMinStddev*mean)
+
+		c := daily[date]
+		if c > mean && c > mean+stddevThreshold*stddev {
+			anomalies = append(anomalies, Anomaly{
+				Date:      date,
+				Cost:      math.Round(c*100) / 100,
+				Expected:  math.Round(mean*100) / 100,
+				Deviation: math.Round((c-mean)*100) / 100,
+				Currency:  currency,
+			})
+		}
+	}
+
+	return anomalies, nil
+}
@@ -0,0 +1,59 @@
+package cost
+
+import (
+	"testing"
+
+	"github.com/azguard/azguard/internal/storage"
+)
+
+// dailyCosts records one Storage cost per day from 2026-09-01.
+func dailyCosts(t *testing.T, costs ...float64) *Service {
+	t.Helper()
+	db := newTestDB(t)
+	var records []storage.CostRecord
+	for i, c := range costs {
+		records = append(records, costRecord(addDays("2026-09-01", i), "Storage", "USD", c))
+	}
+	if err := db.SaveCostRecords(records); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(db)
+}
+
+func TestDetectAnomaliesFlagsSpikeDay(t *testing.T) {
+	svc := dailyCosts(t, 10, 12, 9, 11, 10, 10, 11, 45, 10, 11)
+
+	anomalies, err := svc.DetectAnomalies(CostFilter{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Anomaly{Date: "2026-09-08", Cost: 45, Expected: 10.43, Deviation: 34.57, Currency: "USD"}
+	if len(anomalies) != 1 || anomalies[0] != want {
+		t.Errorf("anomalies = %+v, want only %+v", anomalies, want)
+	}
+}
+
+func TestDetectAnomaliesIgnoresSmallRiseOnFlatSpend(t *testing.T) {
+	// Zero variance must not turn every increase into an anomaly
+	svc := dailyCosts(t, 10, 10, 10, 10, 10, 10, 10, 10.01, 10.5)
+
+	anomalies, err := svc.DetectAnomalies(CostFilter{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anomalies) != 0 {
+		t.Errorf("anomalies = %+v, want none", anomalies)
+	}
+}
+
+func TestDetectAnomaliesNeedsHistory(t *testing.T) {
+	svc := dailyCosts(t, 10, 50)
+
+	anomalies, err := svc.DetectAnomalies(CostFilter{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anomalies) != 0 {
+		t.Errorf("anomalies = %+v, want none before %d days of history", anomalies, anomalyMinHistory)
+	}
+}
@@ -0,0 +1,150 @@
+package cost
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/azguard/azguard/internal/cloud/azure"
+)
+
+// Circuit breaker defaults used when none are configured.
+const (
+	DefaultBreakerThreshold = 3
+	DefaultBreakerCooldown  = time.Minute
+)
+
+// ErrCircuitOpen is returned instead of calling a cloud API that has failed
+// repeatedly, until its cooldown has passed.
+var ErrCircuitOpen = errors.New("cloud API circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker stops calling a cloud API after threshold consecutive
+// transient failures. Once cooldown has passed a single trial call is let
+// through; its success closes the breaker and its failure reopens it.
+// Errors that aren't transient, such as rejected credentials, show the API is
+// answering and count as successes.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// call runs fn unless the breaker is open. A threshold of zero disables the
+// breaker.
+func (b *circuitBreaker) call(fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.record(err)
+	return err
+}
+
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	switch b.state {
+	case breakerOpen:
+		wait := b.cooldown - b.now().Sub(b.openedAt)
+		if wait > 0 {
+			return fmt.Errorf("%w after %d consecutive failures; retrying in %s", ErrCircuitOpen, b.failures, wait.Round(time.Second))
+		}
+		b.state = breakerHalfOpen
+		return nil
+	case breakerHalfOpen:
+		// A trial call is already in flight
+		return fmt.Errorf("%w; waiting on a trial request", ErrCircuitOpen)
+	}
+	return nil
+}
+
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 {
+		return
+	}
+
+	if err == nil || !azure.IsTransient(err) {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = b.now()
+		slog.Warn("cloud API circuit breaker opened", "failures", b.failures, "cooldown", b.cooldown, "err", err)
+	}
+}
+
+func (b *circuitBreaker) configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.threshold = threshold
+	b.cooldown = cooldown
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+// breakerSet keeps one breaker per provider name, so an outage at one cloud
+// doesn't stop calls to another. Subscriptions of the same cloud share one.
+type breakerSet struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	byName    map[string]*circuitBreaker
+}
+
+func newBreakerSet(threshold int, cooldown time.Duration) *breakerSet {
+	return &breakerSet{threshold: threshold, cooldown: cooldown, byName: make(map[string]*circuitBreaker)}
+}
+
+func (s *breakerSet) get(name string) *circuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.byName[name]
+	if !ok {
+		b = newCircuitBreaker(s.threshold, s.cooldown)
+		s.byName[name] = b
+	}
+	return b
+}
+
+func (s *breakerSet) configure(threshold int, cooldown time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.threshold = threshold
+	s.cooldown = cooldown
+	for _, b := range s.byName {
+		b.configure(threshold, cooldown)
+	}
+}
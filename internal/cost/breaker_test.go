@@ -0,0 +1,143 @@
+package cost
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/azguard/azguard/internal/cloud/azure"
+	"github.com/azguard/azguard/internal/storage"
+)
+
+// failingProvider answers with err until it is cleared, counting the calls
+// that reach it.
+type failingProvider struct {
+	fakeProvider
+	err   error
+	calls int
+}
+
+func (f *failingProvider) QueryCostsByService(ctx context.Context, startDate, endDate string) ([]storage.CostRecord, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.fakeProvider.QueryCostsByService(ctx, startDate, endDate)
+}
+
+// fakeClock is a settable time source for breakers.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newBreakerTestService(t *testing.T, p CloudCostProvider) (*Service, *fakeClock) {
+	t.Helper()
+	svc := NewService(newTestDB(t), p)
+	svc.SetCircuitBreaker(3, time.Minute)
+	clock := &fakeClock{now: time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)}
+	svc.breakers.get(p.Name()).now = clock.Now
+	return svc, clock
+}
+
+func fetchOnce(svc *Service, p CloudCostProvider) error {
+	_, err := svc.fetchProvider(context.Background(), p, GranularityService, "2026-10-01", "2026-10-14")
+	return err
+}
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	p := &failingProvider{fakeProvider: fakeProvider{account: "sub-1"}, err: &azure.RequestError{Operation: "query", StatusCode: 503}}
+	svc, _ := newBreakerTestService(t, p)
+
+	for i := 0; i < 3; i++ {
+		if err := fetchOnce(svc, p); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: err = %v, want the provider's error", i+1, err)
+		}
+	}
+
+	// Open: the API is no longer called
+	if err := fetchOnce(svc, p); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("err = %v, want ErrCircuitOpen", err)
+	}
+	if p.calls != 3 {
+		t.Errorf("provider called %d times, want 3", p.calls)
+	}
+}
+
+func TestCircuitBreakerRecoversAfterCooldown(t *testing.T) {
+	p := &failingProvider{fakeProvider: fakeProvider{account: "sub-1"}, err: &azure.RequestError{Operation: "query", StatusCode: 503}}
+	svc, clock := newBreakerTestService(t, p)
+	for i := 0; i < 3; i++ {
+		_ = fetchOnce(svc, p)
+	}
+
+	clock.Advance(59 * time.Second)
+	if err := fetchOnce(svc, p); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("before the cooldown: err = %v, want ErrCircuitOpen", err)
+	}
+
+	// Half-open: a failed trial reopens the breaker for another cooldown
+	clock.Advance(time.Second)
+	if err := fetchOnce(svc, p); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("trial call: err = %v, want the provider's error", err)
+	}
+	if p.calls != 4 {
+		t.Fatalf("provider called %d times, want one trial call", p.calls)
+	}
+	if err := fetchOnce(svc, p); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after a failed trial: err = %v, want ErrCircuitOpen", err)
+	}
+
+	// A successful trial closes it
+	clock.Advance(time.Minute)
+	p.err = nil
+	for i := 0; i < 3; i++ {
+		if err := fetchOnce(svc, p); err != nil {
+			t.Fatalf("call %d after recovery: %v", i+1, err)
+		}
+	}
+	if p.calls != 7 {
+		t.Errorf("provider called %d times, want 7", p.calls)
+	}
+}
+
+func TestCircuitBreakerAllowsOneTrialWhileHalfOpen(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)}
+	b := newCircuitBreaker(1, time.Minute)
+	b.now = clock.Now
+	unavailable := &azure.RequestError{Operation: "query", StatusCode: 503}
+	_ = b.call(func() error { return unavailable })
+
+	clock.Advance(time.Minute)
+	calls := 0
+	err := b.call(func() error {
+		calls++
+		// A second caller arrives while the trial is in flight
+		if err := b.call(func() error { calls++; return nil }); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("concurrent call: err = %v, want ErrCircuitOpen", err)
+		}
+		return nil
+	})
+	if err != nil || calls != 1 {
+		t.Errorf("trial: err = %v after %d calls, want success after 1", err, calls)
+	}
+	if err := b.call(func() error { return nil }); err != nil {
+		t.Errorf("after the trial: err = %v, want the breaker closed", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresPermanentErrors(t *testing.T) {
+	p := &failingProvider{fakeProvider: fakeProvider{account: "sub-1"}, err: &azure.RequestError{Operation: "query", StatusCode: 401}}
+	svc, _ := newBreakerTestService(t, p)
+
+	// Rejected credentials mean the API is up, so every call goes through
+	for i := 0; i < 5; i++ {
+		if err := fetchOnce(svc, p); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: breaker opened on an auth error", i+1)
+		}
+	}
+	if p.calls != 5 {
+		t.Errorf("provider called %d times, want 5", p.calls)
+	}
+}
@@ -0,0 +1,90 @@
+package cost
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/azguard/azguard/internal/storage"
+)
+
+type BurnRate struct {
+	PeriodStart       string  `json:"period_start"`
+	PeriodEnd         string  `json:"period_end"`
+	MTDSpend          float64 `json:"mtd_spend"`
+	DailyAverage      float64 `json:"daily_average"`
+	ProjectedMonthEnd float64 `json:"projected_month_end"`
+	DaysElapsed       int     `json:"days_elapsed"`
+	DaysRemaining     int     `json:"days_remaining"`
+	Currency          string  `json:"currency"`
+}
+
+// GetBurnRate projects spend at the end of the current billing period, which
+// starts on the configured billing cycle day, from its daily burn so far.
+// PeriodEnd is the first day of the next period. Elapsed days run through
+// the latest day with stored costs rather than today, since billing data
+// lags by a day or two; at least one day is always counted so projections
+// early in the period never divide by zero.
+func (s *Service) GetBurnRate(ctx context.Context) (*BurnRate, error) {
+	return s.burnRateAt(time.Now())
+}
+
+func (s *Service) burnRateAt(now time.Time) (*BurnRate, error) {
+	start, end := billingPeriodAt(now, s.billingCycleStartDay)
+	startDate, endDate := start.Format("2006-01-02"), end.Format("2006-01-02")
+
+	records, err := s.db.GetCostRecords(storage.CostFilter{
+		StartDate: startDate,
+		EndDate:   endDate,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	daysInPeriod := daysBetween(start, end)
+
+	costs, currency, err := s.recordCosts(records)
+	if err != nil {
+		return nil, err
+	}
+
+	var mtdSpend float64
+	var latest string
+	for i, r := range records {
+		if r.Date >= endDate {
+			continue
+		}
+		mtdSpend += costs[i]
+		if r.Date > latest {
+			latest = r.Date
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	daysElapsed := daysBetween(start, today) + 1
+	if t, err := time.Parse("2006-01-02", latest); err == nil {
+		daysElapsed = daysBetween(start, t) + 1
+	}
+	if daysElapsed < 1 {
+		daysElapsed = 1
+	}
+
+	dailyAverage := mtdSpend / float64(daysElapsed)
+	daysRemaining := daysInPeriod - daysElapsed
+
+	return &BurnRate{
+		PeriodStart:       startDate,
+		PeriodEnd:         endDate,
+		MTDSpend:          math.Round(mtdSpend*100) / 100,
+		DailyAverage:      math.Round(dailyAverage*100) / 100,
+		ProjectedMonthEnd: math.Round((mtdSpend+dailyAverage*float64(daysRemaining))*100) / 100,
+		DaysElapsed:       daysElapsed,
+		DaysRemaining:     daysRemaining,
+		Currency:          currency,
+	}, nil
+}
+
+// daysBetween counts the whole days from one midnight UTC to another.
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
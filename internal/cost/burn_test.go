@@ -0,0 +1,90 @@
+package cost
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azguard/azguard/internal/storage"
+)
+
+// dailySpend records cost on each day from start for days days.
+func dailySpend(start string, days int, cost float64) []storage.CostRecord {
+	first, _ := time.Parse("2006-01-02", start)
+	records := make([]storage.CostRecord, days)
+	for i := range records {
+		records[i] = costRecord(first.AddDate(0, 0, i).Format("2006-01-02"), "Storage", "USD", cost)
+	}
+	return records
+}
+
+func burnRateOn(t *testing.T, svc *Service, date string) *BurnRate {
+	t.Helper()
+	now, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	burn, err := svc.burnRateAt(now.Add(15 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return burn
+}
+
+func TestBurnRateProjectsPartialMonth(t *testing.T) {
+	db := newTestDB(t)
+	// Costs lag, so the latest record is four days before today
+	if err := db.SaveCostRecords(dailySpend("2026-10-01", 10, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	burn := burnRateOn(t, NewService(db), "2026-10-14")
+	want := BurnRate{
+		PeriodStart:       "2026-10-01",
+		PeriodEnd:         "2026-11-01",
+		MTDSpend:          100,
+		DailyAverage:      10,
+		ProjectedMonthEnd: 310,
+		DaysElapsed:       10,
+		DaysRemaining:     21,
+		Currency:          "USD",
+	}
+	if *burn != want {
+		t.Errorf("burn = %+v, want %+v", *burn, want)
+	}
+}
+
+func TestBurnRateFollowsBillingCycleStartDay(t *testing.T) {
+	db := newTestDB(t)
+	// The 14th belongs to the previous billing period
+	if err := db.SaveCostRecords(dailySpend("2026-10-14", 6, 6)); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(db)
+	svc.SetBillingCycleStartDay(15)
+
+	burn := burnRateOn(t, svc, "2026-10-20")
+	want := BurnRate{
+		PeriodStart:       "2026-10-15",
+		PeriodEnd:         "2026-11-15",
+		MTDSpend:          30,
+		DailyAverage:      6,
+		ProjectedMonthEnd: 186,
+		DaysElapsed:       5,
+		DaysRemaining:     26,
+		Currency:          "USD",
+	}
+	if *burn != want {
+		t.Errorf("burn = %+v, want %+v", *burn, want)
+	}
+}
+
+func TestBurnRateOnFirstDayOfPeriod(t *testing.T) {
+	svc := NewService(newTestDB(t))
+	svc.SetBillingCycleStartDay(31)
+
+	// With no costs yet, one day is counted rather than none
+	burn := burnRateOn(t, svc, "2026-02-28")
+	if burn.PeriodStart != "2026-02-28" || burn.DaysElapsed != 1 || burn.DaysRemaining != 30 || burn.ProjectedMonthEnd != 0 {
+		t.Errorf("burn = %+v, want one elapsed day of a period starting 2026-02-28", *burn)
+	}
+}
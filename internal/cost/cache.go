@@ -0,0 +1,62 @@
+package cost
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long trend and forecast results are reused when no
+// TTL is configured.
+const DefaultCacheTTL = 5 * time.Minute
+
+// resultCache holds computed results for a limited time. An entry is also
+// discarded once the cost records it was computed from change, which the
+// caller tracks with a version number such as storage.DB.CostVersion.
+type resultCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value   interface{}
+	version uint64
+	expires time.Time
+}
+
+func newResultCache(ttl time.Duration) *resultCache {
+	return &resultCache{ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+func (c *resultCache) get(key string, version uint64) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if entry.version != version || time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *resultCache) set(key string, version uint64, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 {
+		return
+	}
+	c.entries[key] = cacheEntry{value: value, version: version, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *resultCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ttl = ttl
+	c.entries = make(map[string]cacheEntry)
+}
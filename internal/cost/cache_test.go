@@ -0,0 +1,83 @@
+package cost
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/azguard/azguard/internal/storage"
+)
+
+// openTwice opens one database file through two connections. Writes through
+// the second don't change the first's CostVersion, so they only show up in
+// results the first computes afresh.
+func openTwice(t *testing.T) (*storage.DB, *storage.DB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "azguard.db")
+	var dbs [2]*storage.DB
+	for i := range dbs {
+		db, err := storage.New(path)
+		if err != nil {
+			t.Fatalf("storage.New: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		dbs[i] = db
+	}
+	return dbs[0], dbs[1]
+}
+
+func currentMonthTotal(t *testing.T, svc *Service) float64 {
+	t.Helper()
+	trend, err := svc.GetTrendAnalysis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trend.CurrentMonth
+}
+
+func TestTrendCacheHitWithinTTL(t *testing.T) {
+	db, other := openTwice(t)
+	monthStart, _ := GetCurrentMonthDateRange()
+	if err := db.SaveCostRecord(costRecord(monthStart, "Storage", "USD", 10)); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(db)
+	svc.SetCacheTTL(time.Hour)
+
+	if got := currentMonthTotal(t, svc); got != 10 {
+		t.Fatalf("current month = %v, want 10", got)
+	}
+
+	// Served from the cache, so the other connection's write isn't seen
+	if err := other.SaveCostRecord(costRecord(monthStart, "Functions", "USD", 5)); err != nil {
+		t.Fatal(err)
+	}
+	if got := currentMonthTotal(t, svc); got != 10 {
+		t.Errorf("current month = %v, want the cached 10", got)
+	}
+
+	// A write through the service's own database invalidates the entry
+	if err := db.SaveCostRecord(costRecord(monthStart, "Key Vault", "USD", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := currentMonthTotal(t, svc); got != 16 {
+		t.Errorf("current month = %v, want a fresh 16", got)
+	}
+}
+
+func TestTrendCacheDisabledWithZeroTTL(t *testing.T) {
+	db, other := openTwice(t)
+	monthStart, _ := GetCurrentMonthDateRange()
+	svc := NewService(db)
+	svc.SetCacheTTL(0)
+
+	if got := currentMonthTotal(t, svc); got != 0 {
+		t.Fatalf("current month = %v, want 0", got)
+	}
+	if err := other.SaveCostRecord(costRecord(monthStart, "Storage", "USD", 10)); err != nil {
+		t.Fatal(err)
+	}
+	if got := currentMonthTotal(t, svc); got != 10 {
+		t.Errorf("current month = %v, want 10 without caching", got)
+	}
+}
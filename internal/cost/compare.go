@@ -0,0 +1,139 @@
+package cost
+
+import (
+	"math"
+	"sort"
+)
+
+type PeriodComparison struct {
+	PeriodA       string         `json:"period_a"`
+	PeriodB       string         `json:"period_b"`
+	TotalA        float64        `json:"total_a"`
+	TotalB        float64        `json:"total_b"`
+	Delta         float64        `json:"delta"`
+	PercentChange float64        `json:"percent_change"`
+	Currency      string         `json:"currency"`
+	Services      []ServiceDelta `json:"services"`
+}
+
+type ServiceDelta struct {
+	Service       string  `json:"service"`
+	CostA         float64 `json:"cost_a"`
+	CostB         float64 `json:"cost_b"`
+	Delta         float64 `json:"delta"`
+	PercentChange float64 `json:"percent_change"`
+	Status        string  `json:"status"`
+}
+
+// ComparePeriods diffs per-service spend from period a to period b. Services
+// present in only one period are treated as zero in the other and marked
+// "added" or "removed". Services are ordered by the size of their change.
+// When the periods were billed in different currencies, both are converted
+// to the target currency.
+func (s *Service) ComparePeriods(a, b CostFilter) (*PeriodComparison, error) {
+	summaryA, err := s.GetCostSummary(a)
+	if err != nil {
+		return nil, err
+	}
+	summaryB, err := s.GetCostSummary(b)
+	if err != nil {
+		return nil, err
+	}
+	if summaryA.Currency != summaryB.Currency {
+		for _, summary := range []*CostSummary{summaryA, summaryB} {
+			if err := s.convertSummary(summary); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	services := make(map[string]bool)
+	for name := range summaryA.ByService {
+		services[name] = true
+	}
+	for name := range summaryB.ByService {
+		services[name] = true
+	}
+
+	var deltas []ServiceDelta
+	for name := range services {
+		costA, inA := summaryA.ByService[name]
+		costB, inB := summaryB.ByService[name]
+
+		status := "changed"
+		switch {
+		case !inA:
+			status = "added"
+		case !inB:
+			status = "removed"
+		case costA == costB:
+			status = "unchanged"
+		}
+
+		deltas = append(deltas, ServiceDelta{
+			Service:       name,
+			CostA:         roundCents(costA),
+			CostB:         roundCents(costB),
+			Delta:         roundCents(costB - costA),
+			PercentChange: percentChange(costA, costB),
+			Status:        status,
+		})
+	}
+
+	sort.Slice(deltas, func(i, j int) bool {
+		di, dj := math.Abs(deltas[i].Delta), math.Abs(deltas[j].Delta)
+		if di != dj {
+			return di > dj
+		}
+		return deltas[i].Service < deltas[j].Service
+	})
+
+	return &PeriodComparison{
+		PeriodA:       summaryA.Period,
+		PeriodB:       summaryB.Period,
+		TotalA:        roundCents(summaryA.TotalCost),
+		TotalB:        roundCents(summaryB.TotalCost),
+		Delta:         roundCents(summaryB.TotalCost - summaryA.TotalCost),
+		PercentChange: percentChange(summaryA.TotalCost, summaryB.TotalCost),
+		Currency:      summaryB.Currency,
+		Services:      deltas,
+	}, nil
+}
+
+// convertSummary converts a summary's per-service and total spend to the
+// target currency.
+func (s *Service) convertSummary(summary *CostSummary) error {
+	if summary.Currency == s.targetCurrency {
+		return nil
+	}
+	if s.converter == nil {
+		return errMixedCurrencies(s.targetCurrency)
+	}
+
+	byService := make(map[string]float64, len(summary.ByService))
+	var total float64
+	for name, amount := range summary.ByService {
+		converted, err := s.toTargetCurrency(amount, summary.Currency)
+		if err != nil {
+			return err
+		}
+		byService[name] = converted
+		total += converted
+	}
+	summary.ByService = byService
+	summary.TotalCost = total
+	summary.Currency = s.targetCurrency
+	return nil
+}
+
+// percentChange is 0 when there is no baseline to compare against.
+func percentChange(from, to float64) float64 {
+	if from == 0 {
+		return 0
+	}
+	return roundCents((to - from) / from * 100)
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,79 @@
+package cost
+
+import (
+	"testing"
+
+	"github.com/azguard/azguard/internal/storage"
+)
+
+var (
+	september = CostFilter{StartDate: "2026-09-01", EndDate: "2026-09-30"}
+	october   = CostFilter{StartDate: "2026-10-01", EndDate: "2026-10-31"}
+)
+
+func TestComparePeriodsAddedRemovedAndChanged(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.SaveCostRecords([]storage.CostRecord{
+		costRecord("2026-09-10", "Storage", "USD", 10),
+		costRecord("2026-09-10", "Functions", "USD", 5),
+		costRecord("2026-09-10", "Blob", "USD", 3),
+		costRecord("2026-10-10", "Storage", "USD", 15),
+		costRecord("2026-10-10", "Blob", "USD", 3),
+		costRecord("2026-10-10", "Containers", "USD", 4),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewService(db).ComparePeriods(september, october)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalA != 18 || result.TotalB != 22 || result.Delta != 4 || result.PercentChange != 22.22 {
+		t.Errorf("totals = %.2f to %.2f (delta %.2f, %.2f%%), want 18.00 to 22.00 (delta 4.00, 22.22%%)",
+			result.TotalA, result.TotalB, result.Delta, result.PercentChange)
+	}
+
+	want := []ServiceDelta{
+		{Service: "Functions", CostA: 5, CostB: 0, Delta: -5, PercentChange: -100, Status: "removed"},
+		{Service: "Storage", CostA: 10, CostB: 15, Delta: 5, PercentChange: 50, Status: "changed"},
+		{Service: "Containers", CostA: 0, CostB: 4, Delta: 4, PercentChange: 0, Status: "added"},
+		{Service: "Blob", CostA: 3, CostB: 3, Delta: 0, PercentChange: 0, Status: "unchanged"},
+	}
+	if len(result.Services) != len(want) {
+		t.Fatalf("services = %+v, want %+v", result.Services, want)
+	}
+	for i := range want {
+		if result.Services[i] != want[i] {
+			t.Errorf("service %d = %+v, want %+v", i, result.Services[i], want[i])
+		}
+	}
+}
+
+func TestComparePeriodsConvertsDifferentCurrencies(t *testing.T) {
+	svc := newMixedCurrencyService(t, []storage.CostRecord{
+		costRecord("2026-09-10", "Storage", "EUR", 10),
+		costRecord("2026-10-10", "Storage", "USD", 25),
+	})
+
+	result, err := svc.ComparePeriods(september, october)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Currency != "USD" || result.TotalA != 20 || result.TotalB != 25 || result.Delta != 5 {
+		t.Errorf("got %.2f to %.2f %s, want 20.00 to 25.00 USD", result.TotalA, result.TotalB, result.Currency)
+	}
+}
+
+func TestComparePeriodsRejectsDifferentCurrenciesWithoutRates(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.SaveCostRecords([]storage.CostRecord{
+		costRecord("2026-09-10", "Storage", "EUR", 10),
+		costRecord("2026-10-10", "Storage", "USD", 25),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewService(db).ComparePeriods(september, october); err == nil {
+		t.Error("expected an error comparing EUR with USD without exchange rates")
+	}
+}
@@ -0,0 +1,50 @@
+package cost
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CurrencyConverter converts amounts between ISO 4217 currency codes.
+type CurrencyConverter interface {
+	Convert(amount float64, from, to string) (float64, error)
+}
+
+// StaticRateConverter converts using fixed exchange rates. Each rate is the
+// value of one unit of that currency expressed in the base currency.
+type StaticRateConverter struct {
+	base  string
+	rates map[string]float64
+}
+
+func NewStaticRateConverter(base string, rates map[string]float64) *StaticRateConverter {
+	normalized := make(map[string]float64, len(rates)+1)
+	for code, rate := range rates {
+		normalized[strings.ToUpper(code)] = rate
+	}
+	base = strings.ToUpper(base)
+	normalized[base] = 1
+
+	return &StaticRateConverter{
+		base:  base,
+		rates: normalized,
+	}
+}
+
+func (c *StaticRateConverter) Convert(amount float64, from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return amount, nil
+	}
+
+	fromRate, ok := c.rates[from]
+	if !ok || fromRate <= 0 {
+		return 0, fmt.Errorf("no exchange rate configured for %s", from)
+	}
+	toRate, ok := c.rates[to]
+	if !ok || toRate <= 0 {
+		return 0, fmt.Errorf("no exchange rate configured for %s", to)
+	}
+
+	return amount * fromRate / toRate, nil
+}
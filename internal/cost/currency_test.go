@@ -0,0 +1,111 @@
+package cost
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/azguard/azguard/internal/storage"
+)
+
+// newMixedCurrencyService returns a service converting to USD, where one EUR
+// is worth two dollars, over a database holding records.
+func newMixedCurrencyService(t *testing.T, records []storage.CostRecord) *Service {
+	t.Helper()
+	db := newTestDB(t)
+	if err := db.SaveCostRecords(records); err != nil {
+		t.Fatalf("SaveCostRecords: %v", err)
+	}
+	svc := NewService(db)
+	svc.SetCurrencyConverter(NewStaticRateConverter("USD", map[string]float64{"EUR": 2}), "USD")
+	return svc
+}
+
+func costRecord(date, service, currency string, cost float64) storage.CostRecord {
+	return storage.CostRecord{
+		SubscriptionID: "sub-1",
+		Provider:       "azure",
+		ServiceName:    service,
+		Date:           date,
+		Currency:       currency,
+		Cost:           cost,
+	}
+}
+
+func TestTrendAnalysisMergesCurrenciesPerMonth(t *testing.T) {
+	monthStart, _ := GetCurrentMonthDateRange()
+	lastMonth := addDays(monthStart, -1)
+	svc := newMixedCurrencyService(t, []storage.CostRecord{
+		costRecord(monthStart, "Storage", "USD", 100),
+		costRecord(monthStart, "Functions", "EUR", 50),
+		costRecord(lastMonth, "Storage", "USD", 100),
+	})
+
+	trend, err := svc.GetTrendAnalysis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trend.CurrentMonth != 200 || trend.PreviousMonth != 100 {
+		t.Errorf("current = %.2f, previous = %.2f, want 200 and 100", trend.CurrentMonth, trend.PreviousMonth)
+	}
+	if trend.Currency != "USD" || trend.Trend != "increasing" {
+		t.Errorf("got %s %s, want USD increasing", trend.Currency, trend.Trend)
+	}
+}
+
+func TestMonthlyCostsNeedConverterForMixedCurrencies(t *testing.T) {
+	monthStart, _ := GetCurrentMonthDateRange()
+	db := newTestDB(t)
+	if err := db.SaveCostRecords([]storage.CostRecord{
+		costRecord(monthStart, "Storage", "USD", 100),
+		costRecord(monthStart, "Functions", "EUR", 50),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewService(db).GetTrendAnalysis(); err == nil {
+		t.Error("expected an error summing USD and EUR without exchange rates")
+	}
+}
+
+func TestDetectAnomaliesConvertsCurrencies(t *testing.T) {
+	day := time.Now().AddDate(0, 0, -10).Format("2006-01-02")
+	var records []storage.CostRecord
+	for i := 0; i < 3; i++ {
+		records = append(records, costRecord(addDays(day, i), "Storage", "USD", 10))
+	}
+	// 5 USD plus 5 EUR is 15 USD, above the flat 10 USD baseline
+	spike := addDays(day, 3)
+	records = append(records,
+		costRecord(spike, "Storage", "USD", 5),
+		costRecord(spike, "Functions", "EUR", 5),
+	)
+	svc := newMixedCurrencyService(t, records)
+
+	anomalies, err := svc.DetectAnomalies(CostFilter{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anomalies) != 1 || anomalies[0].Date != spike || anomalies[0].Cost != 15 {
+		t.Fatalf("anomalies = %+v, want one of 15.00 on %s", anomalies, spike)
+	}
+	if anomalies[0].Currency != "USD" {
+		t.Errorf("currency = %s, want USD", anomalies[0].Currency)
+	}
+}
+
+func TestBurnRateConvertsCurrencies(t *testing.T) {
+	monthStart, _ := GetCurrentMonthDateRange()
+	svc := newMixedCurrencyService(t, []storage.CostRecord{
+		costRecord(monthStart, "Storage", "USD", 10),
+		costRecord(monthStart, "Functions", "EUR", 10),
+	})
+
+	burn, err := svc.GetBurnRate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if burn.MTDSpend != 30 || burn.Currency != "USD" {
+		t.Errorf("month-to-date spend = %.2f %s, want 30.00 USD", burn.MTDSpend, burn.Currency)
+	}
+}
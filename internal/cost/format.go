@@ -0,0 +1,75 @@
+package cost
+
+import (
+	"strconv"
+	"strings"
+)
+
+type currencyFormat struct {
+	symbol   string
+	decimals int
+}
+
+// currencyFormats covers the currencies Azure commonly bills in. Codes not
+// listed here are printed with the code as a suffix and two decimals.
+var currencyFormats = map[string]currencyFormat{
+	"USD": {"$", 2},
+	"EUR": {"€", 2},
+	"GBP": {"£", 2},
+	"JPY": {"¥", 0},
+	"CNY": {"CN¥", 2},
+	"INR": {"₹", 2},
+	"KRW": {"₩", 0},
+	"CAD": {"CA$", 2},
+	"AUD": {"A$", 2},
+	"NZD": {"NZ$", 2},
+	"BRL": {"R$", 2},
+	"CHF": {"CHF ", 2},
+	"SEK": {"SEK ", 2},
+	"NOK": {"NOK ", 2},
+	"DKK": {"DKK ", 2},
+}
+
+// FormatMoney formats an amount in the given currency with its symbol, its
+// usual number of decimal places and thousands separators, e.g. "$1,234.50",
+// "€12.00" or "¥1,235". An empty currency is treated as USD.
+func FormatMoney(amount float64, currency string) string {
+	currency = strings.ToUpper(currency)
+	if currency == "" {
+		currency = "USD"
+	}
+
+	f, known := currencyFormats[currency]
+	if !known {
+		f = currencyFormat{decimals: 2}
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	number := groupThousands(strconv.FormatFloat(amount, 'f', f.decimals, 64))
+	if !known {
+		return sign + number + " " + currency
+	}
+	return sign + f.symbol + number
+}
+
+// groupThousands inserts commas into the integer part of a formatted number.
+func groupThousands(number string) string {
+	intPart, fracPart := number, ""
+	if i := strings.IndexByte(number, '.'); i >= 0 {
+		intPart, fracPart = number[:i], number[i:]
+	}
+
+	var b strings.Builder
+	for i, digit := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return b.String() + fracPart
+}
@@ -0,0 +1,24 @@
+package cost
+
+import "testing"
+
+func TestFormatMoney(t *testing.T) {
+	for _, tt := range []struct {
+		amount   float64
+		currency string
+		want     string
+	}{
+		{1234.5, "USD", "$1,234.50"},
+		{0, "", "$0.00"},
+		{-42.126, "usd", "-$42.13"},
+		{12, "EUR", "€12.00"},
+		{1234567.891, "EUR", "€1,234,567.89"},
+		{1234.6, "JPY", "¥1,235"},
+		{999, "JPY", "¥999"},
+		{10, "XYZ", "10.00 XYZ"},
+	} {
+		if got := FormatMoney(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("FormatMoney(%v, %q) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
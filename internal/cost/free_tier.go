@@ -1,8 +1,13 @@
 package cost
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/azguard/azguard/internal/cloud/azure"
 
 	"gopkg.in/yaml.v3"
 )
@@ -18,6 +23,22 @@ type ServiceLimit struct {
 	Unit             string  `yaml:"unit"`
 	Duration         string  `yaml:"duration"`
 	WarningThreshold float64 `yaml:"warning_threshold"`
+
+	// ServiceNames are the billed service names this limit applies to, and
+	// FreeValue is the approximate monthly cost the free allowance covers.
+	ServiceNames []string `yaml:"service_names"`
+	FreeValue    float64  `yaml:"free_value"`
+
+	// Provider is the cloud the limit belongs to; empty means azure
+	Provider string `yaml:"provider"`
+}
+
+// ProviderName returns the limit's provider, defaulting to azure.
+func (l ServiceLimit) ProviderName() string {
+	if l.Provider == "" {
+		return azure.ProviderName
+	}
+	return l.Provider
 }
 
 type BudgetPreset struct {
@@ -25,7 +46,22 @@ type BudgetPreset struct {
 	Description string  `yaml:"description"`
 }
 
-func LoadFreeTierConfig() (*FreeTierConfig, error) {
+// LoadFreeTierConfig reads free tier limits from path when it is set, and
+// otherwise from the first of the usual locations that exists, falling back
+// to built-in Azure defaults.
+func LoadFreeTierConfig(path string) (*FreeTierConfig, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read free tier config: %w", err)
+		}
+		config := &FreeTierConfig{}
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse free tier config %s: %w", path, err)
+		}
+		return config, nil
+	}
+
 	paths := []string{
 		"configs/free_tier_limits.yaml",
 		"./configs/free_tier_limits.yaml",
@@ -53,6 +89,8 @@ func LoadFreeTierConfig() (*FreeTierConfig, error) {
 				Unit:             "hours",
 				Duration:         "12 months",
 				WarningThreshold: 0.8,
+				ServiceNames:     []string{"Virtual Machines"},
+				FreeValue:        7.5,
 			},
 			"blob_storage": {
 				Description:      "Hot Blob Storage",
@@ -60,6 +98,8 @@ func LoadFreeTierConfig() (*FreeTierConfig, error) {
 				Unit:             "GB",
 				Duration:         "always free",
 				WarningThreshold: 0.8,
+				ServiceNames:     []string{"Storage", "Blob Storage"},
+				FreeValue:        0.115,
 			},
 			"functions": {
 				Description:      "Azure Functions",
@@ -67,6 +107,7 @@ func LoadFreeTierConfig() (*FreeTierConfig, error) {
 				Unit:             "executions",
 				Duration:         "always free",
 				WarningThreshold: 0.8,
+				ServiceNames:     []string{"Functions", "Azure Functions"},
 			},
 		},
 		Budgets: map[string]BudgetPreset{
@@ -88,17 +129,39 @@ const (
 )
 
 type ServiceUsage struct {
-	ServiceName string
-	Used        float64
-	Limit       float64
-	Unit        string
-	Status      ResourceStatus
-	PercentUsed float64
+	ServiceName string         `json:"service_name"`
+	Used        float64        `json:"used"`
+	Limit       float64        `json:"limit"`
+	Unit        string         `json:"unit"`
+	Status      ResourceStatus `json:"status"`
+	PercentUsed float64        `json:"percent_used"`
+}
+
+// FindServiceLimit returns the limit whose service_names (or key, with
+// underscores read as spaces) matches a billed service name, or nil. A
+// non-empty provider only matches limits for that provider.
+func (c *FreeTierConfig) FindServiceLimit(provider, serviceName string) *ServiceLimit {
+	for key, limit := range c.Services {
+		if provider != "" && !strings.EqualFold(limit.ProviderName(), provider) {
+			continue
+		}
+		if strings.EqualFold(strings.ReplaceAll(key, "_", " "), serviceName) {
+			l := limit
+			return &l
+		}
+		for _, name := range limit.ServiceNames {
+			if strings.EqualFold(name, serviceName) {
+				l := limit
+				return &l
+			}
+		}
+	}
+	return nil
 }
 
 func CheckServiceUsage(usage float64, limit *ServiceLimit) ServiceUsage {
-	if limit == nil {
-		return ServiceUsage{Status: StatusUnknown}
+	if limit == nil || limit.Limit <= 0 {
+		return ServiceUsage{Used: usage, Status: StatusUnknown}
 	}
 
 	percentUsed := usage / limit.Limit
@@ -121,3 +184,45 @@ func CheckServiceUsage(usage float64, limit *ServiceLimit) ServiceUsage {
 		PercentUsed: percentUsed * 100,
 	}
 }
+
+// CheckFreeTier compares each stored service's spend in the filter range with
+// the dollar value of its free allowance. Services with no matching free tier
+// entry are left out; entries without a free_value are reported as unknown.
+func (s *Service) CheckFreeTier(filter CostFilter) ([]ServiceUsage, error) {
+	config, err := LoadFreeTierConfig(s.freeTierPath)
+	if err != nil {
+		return nil, err
+	}
+
+	byService, currency, err := s.aggregateCosts(filter.storageFilter("ServiceName"))
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []ServiceUsage
+	for service, c := range byService {
+		limit := config.FindServiceLimit(filter.Provider, service)
+		if limit == nil {
+			continue
+		}
+
+		var usage ServiceUsage
+		if limit.FreeValue > 0 {
+			usage = CheckServiceUsage(c, &ServiceLimit{
+				Limit:            limit.FreeValue,
+				Unit:             currency,
+				WarningThreshold: limit.WarningThreshold,
+			})
+		} else {
+			usage = CheckServiceUsage(c, nil)
+			usage.Unit = currency
+		}
+		usage.ServiceName = service
+		usages = append(usages, usage)
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].ServiceName < usages[j].ServiceName
+	})
+	return usages, nil
+}
@@ -0,0 +1,144 @@
+package cost
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azguard/azguard/internal/storage"
+)
+
+// writeFreeTierConfig writes a free tier YAML file and returns its path.
+func writeFreeTierConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "free_tier_limits.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckServiceUsageBoundaries(t *testing.T) {
+	limit := &ServiceLimit{Limit: 10, Unit: "USD", WarningThreshold: 0.8}
+	tests := []struct {
+		used float64
+		want ResourceStatus
+	}{
+		{0, StatusFree},
+		{7.99, StatusFree},
+		{8, StatusWarning},
+		{9.99, StatusWarning},
+		{10, StatusOverage},
+		{25, StatusOverage},
+	}
+	for _, tt := range tests {
+		if got := CheckServiceUsage(tt.used, limit); got.Status != tt.want {
+			t.Errorf("%v of 10 = %s, want %s", tt.used, got.Status, tt.want)
+		}
+	}
+
+	if got := CheckServiceUsage(8, limit); got.PercentUsed != 80 || got.Limit != 10 || got.Unit != "USD" {
+		t.Errorf("usage = %+v, want 80%% of 10 USD", got)
+	}
+	if got := CheckServiceUsage(5, &ServiceLimit{Limit: 10}); got.Status != StatusFree {
+		t.Errorf("without a warning threshold got %s, want free", got.Status)
+	}
+	if got := CheckServiceUsage(5, nil); got.Status != StatusUnknown {
+		t.Errorf("without a limit got %s, want unknown", got.Status)
+	}
+}
+
+func TestCheckFreeTierComparesStoredSpend(t *testing.T) {
+	svc := newMixedCurrencyService(t, []storage.CostRecord{
+		costRecord("2026-09-03", "Virtual Machines", "USD", 6.5),
+		costRecord("2026-09-04", "Storage", "USD", 0.5),
+		costRecord("2026-09-04", "Functions", "USD", 0.01),
+		costRecord("2026-09-05", "Key Vault", "USD", 3),
+	})
+	svc.SetFreeTierPath(writeFreeTierConfig(t, `
+services:
+  virtual_machines:
+    limit: 750
+    unit: hours
+    warning_threshold: 0.8
+    free_value: 7.5
+  blob_storage:
+    service_names: [Storage]
+    warning_threshold: 0.8
+    free_value: 0.115
+  functions:
+    limit: 1000000
+    unit: executions
+`))
+
+	usages, err := svc.CheckFreeTier(CostFilter{StartDate: "2026-09-01", EndDate: "2026-09-30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Key Vault has no free tier entry and is left out
+	want := []struct {
+		service string
+		status  ResourceStatus
+	}{
+		{"Functions", StatusUnknown},
+		{"Storage", StatusOverage},
+		{"Virtual Machines", StatusWarning},
+	}
+	if len(usages) != len(want) {
+		t.Fatalf("usages = %+v, want %d services", usages, len(want))
+	}
+	for i, w := range want {
+		if usages[i].ServiceName != w.service || usages[i].Status != w.status || usages[i].Unit != "USD" {
+			t.Errorf("usage %d = %+v, want %s %s in USD", i, usages[i], w.service, w.status)
+		}
+	}
+}
+
+func TestLoadFreeTierConfigFromCustomPath(t *testing.T) {
+	config, err := LoadFreeTierConfig(writeFreeTierConfig(t, `
+services:
+  virtual_machines:
+    service_names: [Virtual Machines]
+    free_value: 7.5
+  ec2:
+    provider: aws
+    service_names: [Amazon Elastic Compute Cloud - Compute]
+    free_value: 8.5
+  compute_engine:
+    provider: gcp
+    service_names: [Compute Engine]
+    free_value: 6.1
+budgets:
+  custom:
+    amount: 3
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Services) != 3 || config.Budgets["custom"].Amount != 3 {
+		t.Errorf("config = %+v, want only the file's three services and budget", config)
+	}
+
+	if limit := config.FindServiceLimit("aws", "amazon elastic compute cloud - compute"); limit == nil || limit.FreeValue != 8.5 {
+		t.Errorf("aws EC2 limit = %+v, want free value 8.5", limit)
+	}
+	if limit := config.FindServiceLimit("gcp", "Compute Engine"); limit == nil || limit.ProviderName() != "gcp" {
+		t.Errorf("gcp limit = %+v, want the compute_engine entry", limit)
+	}
+	if limit := config.FindServiceLimit("azure", "Virtual Machines"); limit == nil || limit.ProviderName() != "azure" {
+		t.Errorf("azure limit = %+v, want virtual_machines defaulting to azure", limit)
+	}
+	if limit := config.FindServiceLimit("azure", "Compute Engine"); limit != nil {
+		t.Errorf("azure matched %+v, want no limit from another provider", limit)
+	}
+}
+
+func TestLoadFreeTierConfigReportsBadCustomPath(t *testing.T) {
+	if _, err := LoadFreeTierConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing custom file")
+	}
+	if _, err := LoadFreeTierConfig(writeFreeTierConfig(t, "services: [not, a, map]\n")); err == nil {
+		t.Error("expected an error for a malformed custom file")
+	}
+}
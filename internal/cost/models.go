@@ -1,6 +1,11 @@
 package cost
 
 import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/azguard/azguard/internal/storage"
@@ -15,11 +20,58 @@ type CostSummary struct {
 	Forecast        *Forecast         `json:"forecast,omitempty"`
 	MonthlyBreakdown []storage.MonthlyCost `json:"monthly_breakdown,omitempty"`
 	Trend           *TrendAnalysis    `json:"trend,omitempty"`
+	Daily           []DailyCost       `json:"daily,omitempty"`
+
+	WeeklyBreakdown []storage.WeeklyCost `json:"weekly_breakdown,omitempty"`
+	// Warning is set when the summary had to be served from stale data
+	Warning string `json:"warning,omitempty"`
+}
+
+type DailyCost struct {
+	Date      string  `json:"date"`
+	TotalCost float64 `json:"total_cost"`
+	Currency  string  `json:"currency"`
+}
+
+// MultiCloudSummary totals stored costs across every provider.
+type MultiCloudSummary struct {
+	Period      string             `json:"period"`
+	PerProvider map[string]float64 `json:"per_provider"`
+	GrandTotal  float64            `json:"grand_total"`
+	Currency    string             `json:"currency"`
 }
 
 type Forecast struct {
-	NextMonth   float64 `json:"next_month"`
-	Confidence  string  `json:"confidence"`
+	NextMonth   float64        `json:"next_month"`
+	Confidence  string         `json:"confidence"`
+	Method      ForecastMethod `json:"method"`
+	Currency    string         `json:"currency"`
+}
+
+// ForecastMethod selects how local forecasts project the next month.
+type ForecastMethod string
+
+const (
+	ForecastLinear        ForecastMethod = "linear"
+	ForecastMovingAverage ForecastMethod = "moving_average"
+	ForecastExponential   ForecastMethod = "exponential"
+
+	// ForecastAzure marks forecasts returned by the Azure Cost Management API
+	ForecastAzure ForecastMethod = "azure"
+)
+
+const (
+	movingAverageMonths = 3
+	smoothingFactor     = 0.5
+)
+
+func ParseForecastMethod(method string) (ForecastMethod, error) {
+	switch ForecastMethod(method) {
+	case ForecastLinear, ForecastMovingAverage, ForecastExponential:
+		return ForecastMethod(method), nil
+	default:
+		return "", fmt.Errorf("unknown forecast method %q (use linear, moving_average, or exponential)", method)
+	}
 }
 
 type Report struct {
@@ -41,13 +93,58 @@ type MonthlyReport struct {
 type ServiceCost struct {
 	Service string  `json:"service"`
 	Cost    float64 `json:"cost"`
+	Percent float64 `json:"percent"`
+}
+
+// TopServices orders services by cost, highest first, with each one's share
+// of the overall total, and keeps the first n (all of them when n <= 0).
+func TopServices(byService map[string]float64, n int) []ServiceCost {
+	var total float64
+	services := make([]ServiceCost, 0, len(byService))
+	for service, c := range byService {
+		total += c
+		services = append(services, ServiceCost{Service: service, Cost: c})
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Cost != services[j].Cost {
+			return services[i].Cost > services[j].Cost
+		}
+		return services[i].Service < services[j].Service
+	})
+
+	if n > 0 && len(services) > n {
+		services = services[:n]
+	}
+	for i := range services {
+		if total > 0 {
+			services[i].Percent = math.Round(services[i].Cost/total*10000) / 100
+		}
+	}
+	return services
 }
 
 type CostFilter struct {
-	StartDate   string
-	EndDate     string
-	ServiceName string
-	GroupBy     string
+	StartDate      string
+	EndDate        string
+	ServiceName    string
+	Provider       string
+	SubscriptionID string
+	ResourceGroup  string
+	GroupBy        string
+}
+
+// storageFilter converts the filter for a storage query grouped by groupBy.
+func (f CostFilter) storageFilter(groupBy string) storage.CostFilter {
+	return storage.CostFilter{
+		StartDate:      f.StartDate,
+		EndDate:        f.EndDate,
+		ServiceName:    f.ServiceName,
+		Provider:       f.Provider,
+		SubscriptionID: f.SubscriptionID,
+		ResourceGroup:  f.ResourceGroup,
+		GroupBy:        groupBy,
+	}
 }
 
 type Alert struct {
@@ -58,11 +155,47 @@ type Alert struct {
 	Enabled         bool    `json:"enabled"`
 }
 
-func GetCurrentBillingPeriod() (startDate, endDate string) {
-	now := time.Now()
-	startDate = now.Format("2006-01-02")
-	endDate = now.AddDate(0, 1, 0).Format("2006-01-02")
-	return
+// Date ranges returned by GetCurrentBillingPeriod and GetCurrentMonthDateRange
+// run from the first day of the period to the first day of the next one.
+// Use GetCurrentMonthDateRange for calendar-month figures (month-to-date
+// spend, fetching) and GetCurrentBillingPeriod when the figure must line up
+// with an invoice whose cycle starts on another day, as the burn rate does.
+
+// GetCurrentBillingPeriod returns the billing cycle containing today for a
+// cycle that starts on billingCycleStartDay of each month. Start days past
+// the end of a short month fall on its last day, so 31 means "last day of
+// the month". A start day of 1 is the calendar month.
+func GetCurrentBillingPeriod(billingCycleStartDay int) (startDate, endDate string) {
+	start, end := billingPeriodAt(time.Now(), billingCycleStartDay)
+	return start.Format("2006-01-02"), end.Format("2006-01-02")
+}
+
+func billingPeriodAt(now time.Time, startDay int) (start, end time.Time) {
+	if startDay < 1 {
+		startDay = 1
+	}
+	if startDay > 31 {
+		startDay = 31
+	}
+
+	year, month, _ := now.Date()
+	start = cycleStart(year, month, startDay)
+	if now.Day() < start.Day() {
+		start = cycleStart(year, month-1, startDay)
+	}
+	end = cycleStart(start.Year(), start.Month()+1, startDay)
+	return start, end
+}
+
+// cycleStart is startDay of the given month, clamped to the month's length.
+// time.Date normalizes month overflow, so month-1 and month+1 are safe.
+func cycleStart(year int, month time.Month, startDay int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if startDay > lastDay {
+		startDay = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), startDay, 0, 0, 0, 0, time.UTC)
 }
 
 func GetLastNMonths(n int) (startDate, endDate string) {
@@ -82,3 +215,93 @@ func GetCurrentMonthDateRange() (startDate, endDate string) {
 	}
 	return
 }
+
+// ValidateDateRange checks that each non-empty bound is a YYYY-MM-DD date and
+// that start is not after end. Either bound may be empty for an open range.
+func ValidateDateRange(start, end string) error {
+	var startTime, endTime time.Time
+	var err error
+	if start != "" {
+		if startTime, err = time.Parse("2006-01-02", start); err != nil {
+			return fmt.Errorf("invalid start date %q (expected YYYY-MM-DD)", start)
+		}
+	}
+	if end != "" {
+		if endTime, err = time.Parse("2006-01-02", end); err != nil {
+			return fmt.Errorf("invalid end date %q (expected YYYY-MM-DD)", end)
+		}
+	}
+	if start != "" && end != "" && startTime.After(endTime) {
+		return fmt.Errorf("start date %s is after end date %s", start, end)
+	}
+	return nil
+}
+
+// GetMonthDateRange returns the first and last day of a YYYY-MM month.
+func GetMonthDateRange(month string) (startDate, endDate string, err error) {
+	t, err := time.Parse("2006-01", month)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid month %q (expected YYYY-MM)", month)
+	}
+	startDate = t.Format("2006-01-02")
+	endDate = t.AddDate(0, 1, -1).Format("2006-01-02")
+	return
+}
+
+// GetCutoffDate returns the YYYY-MM-DD date that lies the given age before
+// now. Ages are a count followed by d (days), w (weeks), m (months) or
+// y (years), e.g. "365d" or "6m".
+func GetCutoffDate(age string, now time.Time) (string, error) {
+	if len(age) < 2 {
+		return "", fmt.Errorf("invalid age %q (expected e.g. 30d, 12w, 6m, 1y)", age)
+	}
+
+	n, err := strconv.Atoi(age[:len(age)-1])
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("invalid age %q (expected e.g. 30d, 12w, 6m, 1y)", age)
+	}
+
+	switch age[len(age)-1] {
+	case 'd':
+		now = now.AddDate(0, 0, -n)
+	case 'w':
+		now = now.AddDate(0, 0, -7*n)
+	case 'm':
+		now = now.AddDate(0, -n, 0)
+	case 'y':
+		now = now.AddDate(-n, 0, 0)
+	default:
+		return "", fmt.Errorf("invalid age %q (expected e.g. 30d, 12w, 6m, 1y)", age)
+	}
+	return now.Format("2006-01-02"), nil
+}
+
+// ParseDateExpr resolves a date expression to the YYYY-MM-DD range it covers.
+// Expressions are a YYYY-MM-DD date, "today", "yesterday", "last-month", or
+// an age such as "30d", "2w" or "3m" as accepted by GetCutoffDate. Every form
+// except last-month covers a single day, so start and end are equal; callers
+// use start for a lower bound and end for an upper bound.
+func ParseDateExpr(expr string, now time.Time) (startDate, endDate string, err error) {
+	expr = strings.ToLower(strings.TrimSpace(expr))
+	switch expr {
+	case "today":
+		startDate = now.Format("2006-01-02")
+		return startDate, startDate, nil
+	case "yesterday":
+		startDate = now.AddDate(0, 0, -1).Format("2006-01-02")
+		return startDate, startDate, nil
+	case "last-month", "last month":
+		first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return first.AddDate(0, -1, 0).Format("2006-01-02"), first.AddDate(0, 0, -1).Format("2006-01-02"), nil
+	}
+
+	if _, err := time.Parse("2006-01-02", expr); err == nil {
+		return expr, expr, nil
+	}
+
+	date, err := GetCutoffDate(expr, now)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid date %q (expected YYYY-MM-DD, today, yesterday, last-month, or an age like 30d, 2w, 3m)", expr)
+	}
+	return date, date, nil
+}
@@ -0,0 +1,119 @@
+package cost
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBillingPeriodAt(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		now       string
+		startDay  int
+		wantStart string
+		wantEnd   string
+	}{
+		{"calendar month", "2026-10-14", 1, "2026-10-01", "2026-11-01"},
+		{"calendar month in December", "2026-12-31", 1, "2026-12-01", "2027-01-01"},
+		{"mid-month cycle after its start", "2026-10-20", 15, "2026-10-15", "2026-11-15"},
+		{"mid-month cycle before its start", "2026-10-14", 15, "2026-09-15", "2026-10-15"},
+		{"mid-month cycle on its start day", "2026-10-15", 15, "2026-10-15", "2026-11-15"},
+		{"mid-month cycle across the year end", "2027-01-03", 15, "2026-12-15", "2027-01-15"},
+		{"end of month in a short month", "2026-02-28", 31, "2026-02-28", "2026-03-31"},
+		{"end of month before the month's last day", "2026-03-30", 31, "2026-02-28", "2026-03-31"},
+		{"end of month in a leap year", "2028-02-29", 31, "2028-02-29", "2028-03-31"},
+		{"start day 30 in February", "2026-03-01", 30, "2026-02-28", "2026-03-30"},
+		{"out of range start day", "2026-10-14", 0, "2026-10-01", "2026-11-01"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			now, err := time.Parse("2006-01-02", tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start, end := billingPeriodAt(now, tt.startDay)
+			if got := start.Format("2006-01-02"); got != tt.wantStart {
+				t.Errorf("start = %s, want %s", got, tt.wantStart)
+			}
+			if got := end.Format("2006-01-02"); got != tt.wantEnd {
+				t.Errorf("end = %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestGetCurrentBillingPeriodContainsToday(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	for _, day := range []int{1, 15, 31} {
+		start, end := GetCurrentBillingPeriod(day)
+		if today < start || today >= end {
+			t.Errorf("cycle day %d: %s to %s does not contain %s", day, start, end, today)
+		}
+	}
+}
+
+func TestParseDateExpr(t *testing.T) {
+	now := time.Date(2026, 3, 15, 10, 30, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		expr      string
+		wantStart string
+		wantEnd   string
+	}{
+		{"today", "2026-03-15", "2026-03-15"},
+		{"yesterday", "2026-03-14", "2026-03-14"},
+		{"last-month", "2026-02-01", "2026-02-28"},
+		{"Last Month", "2026-02-01", "2026-02-28"},
+		{"30d", "2026-02-13", "2026-02-13"},
+		{"2w", "2026-03-01", "2026-03-01"},
+		{"3m", "2025-12-15", "2025-12-15"},
+		{"2026-01-02", "2026-01-02", "2026-01-02"},
+	} {
+		start, end, err := ParseDateExpr(tt.expr, now)
+		if err != nil {
+			t.Errorf("ParseDateExpr(%q): %v", tt.expr, err)
+			continue
+		}
+		if start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("ParseDateExpr(%q) = %s..%s, want %s..%s", tt.expr, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+func TestParseDateExprRejectsInvalidExpression(t *testing.T) {
+	for _, expr := range []string{"next week", "30x", "2026-13-01", ""} {
+		if _, _, err := ParseDateExpr(expr, time.Now()); err == nil {
+			t.Errorf("ParseDateExpr(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestValidateDateRange(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		start   string
+		end     string
+		wantErr string
+	}{
+		{"open range", "", "", ""},
+		{"start only", "2026-09-01", "", ""},
+		{"end only", "", "2026-09-30", ""},
+		{"single day", "2026-09-01", "2026-09-01", ""},
+		{"ordered range", "2026-09-01", "2026-09-30", ""},
+		{"malformed start", "2026/09/01", "", "invalid start date"},
+		{"impossible end", "", "2026-02-30", "invalid end date"},
+		{"reversed range", "2026-09-30", "2026-09-01", "is after end date"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDateRange(tt.start, tt.end)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
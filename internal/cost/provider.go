@@ -0,0 +1,101 @@
+package cost
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/azguard/azguard/internal/cloud/azure"
+	"github.com/azguard/azguard/internal/storage"
+)
+
+// CloudCostProvider is a source of cost data that Service can fetch from.
+// Records it returns must have Provider set to Name().
+type CloudCostProvider interface {
+	Name() string
+	// Account identifies the subscription, account or project the provider
+	// reads, so the last fetched date can be tracked for each one
+	Account() string
+	// QueryCostsByService returns daily costs per service for the
+	// YYYY-MM-DD date range
+	QueryCostsByService(ctx context.Context, startDate, endDate string) ([]storage.CostRecord, error)
+	// GetForecast returns the provider's own forecast of total spend and
+	// the currency it is in
+	GetForecast(ctx context.Context, granularity string) (float64, string, error)
+}
+
+// ResourceCostProvider is a provider that can also break costs down by
+// resource and meter.
+type ResourceCostProvider interface {
+	CloudCostProvider
+	// QueryCostsByResource returns daily costs per service, resource and
+	// meter for the YYYY-MM-DD date range
+	QueryCostsByResource(ctx context.Context, startDate, endDate string) ([]storage.CostRecord, error)
+}
+
+// Fetch granularities for SetFetchGranularity.
+const (
+	GranularityAuto     = "auto"
+	GranularityService  = "service"
+	GranularityResource = "resource"
+)
+
+// azureProvider adapts an Azure cost client for one subscription.
+type azureProvider struct {
+	client *azure.CostClient
+}
+
+// NewAzureProvider returns a provider that fetches costs for the client's
+// subscription.
+func NewAzureProvider(client *azure.CostClient) CloudCostProvider {
+	return &azureProvider{client: client}
+}
+
+func (p *azureProvider) Name() string {
+	return azure.ProviderName
+}
+
+func (p *azureProvider) Account() string {
+	return p.client.SubscriptionID
+}
+
+func (p *azureProvider) QueryCostsByService(ctx context.Context, startDate, endDate string) ([]storage.CostRecord, error) {
+	result, err := p.client.QueryCostsByService(ctx, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("subscription %s: %w", p.client.SubscriptionID, err)
+	}
+	return p.records(result), nil
+}
+
+func (p *azureProvider) QueryCostsByResource(ctx context.Context, startDate, endDate string) ([]storage.CostRecord, error) {
+	result, err := p.client.QueryCostsByResource(ctx, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("subscription %s: %w", p.client.SubscriptionID, err)
+	}
+	return p.records(result), nil
+}
+
+func (p *azureProvider) records(result *azure.CostQueryResult) []storage.CostRecord {
+	records := make([]storage.CostRecord, 0, len(result.Records))
+	for _, r := range result.Records {
+		records = append(records, storage.CostRecord{
+			SubscriptionID: p.client.SubscriptionID,
+			ResourceGroup:  r.ResourceGroup,
+			ServiceName:    r.ServiceName,
+			Cost:           r.Cost,
+			Currency:       r.Currency,
+			Date:           r.Date,
+			Provider:       azure.ProviderName,
+			ResourceID:     r.ResourceID,
+			Meter:          r.Meter,
+		})
+	}
+	return records
+}
+
+func (p *azureProvider) GetForecast(ctx context.Context, granularity string) (float64, string, error) {
+	result, err := p.client.GetForecast(ctx, granularity)
+	if err != nil {
+		return 0, "", fmt.Errorf("subscription %s: %w", p.client.SubscriptionID, err)
+	}
+	return result.TotalCost, result.Currency, nil
+}
@@ -0,0 +1,210 @@
+package cost
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azguard/azguard/internal/cloud/azure"
+	"github.com/azguard/azguard/internal/storage"
+)
+
+const testSubscription = "00000000-0000-0000-0000-000000000001"
+
+// fakeCostAPI serves Cost Management queries for one day of spend in web-rg:
+// a VM and its disk, or their service totals when grouped by service.
+func fakeCostAPI(t *testing.T, date string) *azure.CostClient {
+	t.Helper()
+	usageDate, _ := time.Parse("2006-01-02", date)
+	day := float64(usageDate.Year()*10000 + int(usageDate.Month())*100 + usageDate.Day())
+	vm := "/subscriptions/" + testSubscription + "/resourceGroups/web-rg/providers/Microsoft.Compute/virtualMachines/web-1"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req azure.CostQueryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var groups []string
+		for _, g := range req.Dataset.Grouping {
+			groups = append(groups, g.Name)
+		}
+
+		props := azure.CostQueryProperties{}
+		switch strings.Join(groups, ",") {
+		case "ResourceId,Meter":
+			props.Columns = []azure.QueryColumn{{Name: "Cost"}, {Name: "UsageDate"}, {Name: "ResourceId"}, {Name: "Meter"}, {Name: "Currency"}}
+			props.Rows = [][]interface{}{
+				{4.0, day, vm, "D2s v3", "USD"},
+				{0.5, day, vm, "P10 Disks", "USD"},
+			}
+		case "ServiceName,Meter":
+			props.Columns = []azure.QueryColumn{{Name: "Cost"}, {Name: "UsageDate"}, {Name: "ServiceName"}, {Name: "Meter"}, {Name: "Currency"}}
+			props.Rows = [][]interface{}{
+				{4.0, day, "Virtual Machines", "D2s v3", "USD"},
+				{0.5, day, "Storage", "P10 Disks", "USD"},
+			}
+		case "ServiceName,ResourceGroup":
+			props.Columns = []azure.QueryColumn{{Name: "Cost"}, {Name: "UsageDate"}, {Name: "ServiceName"}, {Name: "ResourceGroup"}, {Name: "Currency"}}
+			props.Rows = [][]interface{}{
+				{4.0, day, "Virtual Machines", "web-rg", "USD"},
+				{0.5, day, "Storage", "web-rg", "USD"},
+			}
+		default:
+			t.Errorf("unexpected grouping %v", groups)
+			http.Error(w, "unexpected grouping", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(azure.CostQueryResponse{Properties: props})
+	}))
+	t.Cleanup(server.Close)
+
+	client := azure.NewCostClient(testSubscription, nil)
+	client.Token = "token"
+	client.BaseURL = server.URL
+	return client
+}
+
+func TestResourceFetchStoresQueryableRecords(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	db := newTestDB(t)
+	svc := NewService(db, NewAzureProvider(fakeCostAPI(t, today)))
+	if err := svc.SetFetchGranularity(GranularityResource); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.FetchAndStoreCosts(context.Background(), addDays(today, -5), addDays(today, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := db.GetCostRecords(storage.CostFilter{ServiceName: "Virtual Machines"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Meter != "D2s v3" || records[0].ResourceGroup != "web-rg" ||
+		!strings.HasSuffix(records[0].ResourceID, "/virtualMachines/web-1") {
+		t.Fatalf("stored VM records = %+v", records)
+	}
+
+	summary, err := svc.GetCostSummary(CostFilter{ResourceGroup: "web-rg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalCost != 4.5 {
+		t.Errorf("web-rg total = %.2f, want 4.50", summary.TotalCost)
+	}
+}
+
+func TestAutoGranularityKeepsResourceDetail(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	db := newTestDB(t)
+	svc := NewService(db, NewAzureProvider(fakeCostAPI(t, today)))
+	ctx := context.Background()
+	start, end := addDays(today, -5), addDays(today, 5)
+
+	if err := svc.SetFetchGranularity(GranularityResource); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.FetchAndStoreCosts(ctx, start, end); err != nil {
+		t.Fatal(err)
+	}
+	// A routine fetch, such as the one 'cost current' makes
+	if err := svc.SetFetchGranularity(GranularityAuto); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.FetchAndStoreCosts(ctx, start, end); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := db.GetCostRecords(storage.CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if r.ResourceID == "" {
+			t.Errorf("auto fetch stored service-level record %+v", r)
+		}
+	}
+	if len(records) != 2 {
+		t.Errorf("got %d records, want the 2 resource-level ones", len(records))
+	}
+}
+
+func TestServiceFetchReplacesResourceDetail(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	db := newTestDB(t)
+	svc := NewService(db, NewAzureProvider(fakeCostAPI(t, today)))
+	ctx := context.Background()
+	start, end := addDays(today, -5), addDays(today, 5)
+
+	for _, granularity := range []string{GranularityResource, GranularityService} {
+		if err := svc.SetFetchGranularity(granularity); err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.FetchAndStoreCosts(ctx, start, end); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The day's costs must not be counted at both granularities
+	summary, err := svc.GetCostSummary(CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalCost != 4.5 {
+		t.Errorf("total = %.2f, want 4.50", summary.TotalCost)
+	}
+}
+
+func TestServiceFetchStoresResourceGroups(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	svc := NewService(newTestDB(t), NewAzureProvider(fakeCostAPI(t, today)))
+	if err := svc.FetchAndStoreCosts(context.Background(), addDays(today, -5), addDays(today, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := svc.GetCostSummary(CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary.ByResourceGroup["web-rg"]; got != 4.5 {
+		t.Errorf("web-rg spend = %.2f, want 4.50 from a service-level fetch", got)
+	}
+}
+
+func TestResourceFetchRoundTripsResourceAndMeter(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	db := newTestDB(t)
+	svc := NewService(db, NewAzureProvider(fakeCostAPI(t, today)))
+	if err := svc.SetFetchGranularity(GranularityResource); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.FetchAndStoreCosts(context.Background(), addDays(today, -5), addDays(today, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read back the way 'cost export' does
+	vm := "/subscriptions/" + testSubscription + "/resourceGroups/web-rg/providers/Microsoft.Compute/virtualMachines/web-1"
+	want := map[string]storage.CostRecord{
+		"D2s v3":    {SubscriptionID: testSubscription, ResourceGroup: "web-rg", ServiceName: "Virtual Machines", Cost: 4, Currency: "USD", Date: today, Provider: "azure", ResourceID: vm, Meter: "D2s v3"},
+		"P10 Disks": {SubscriptionID: testSubscription, ResourceGroup: "web-rg", ServiceName: "Storage", Cost: 0.5, Currency: "USD", Date: today, Provider: "azure", ResourceID: vm, Meter: "P10 Disks"},
+	}
+	got := 0
+	err := db.EachCostRecord(storage.CostFilter{Provider: "azure", SubscriptionID: testSubscription}, func(r storage.CostRecord) error {
+		got++
+		r.ID = 0
+		if r != want[r.Meter] {
+			t.Errorf("stored %+v, want %+v", r, want[r.Meter])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != len(want) {
+		t.Errorf("read back %d records, want %d", got, len(want))
+	}
+}
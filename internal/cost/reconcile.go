@@ -0,0 +1,162 @@
+package cost
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/azguard/azguard/internal/storage"
+)
+
+type Reconciliation struct {
+	Period        string        `json:"period"`
+	StoredTotal   float64       `json:"stored_total"`
+	LiveTotal     float64       `json:"live_total"`
+	Tolerance     float64       `json:"tolerance"`
+	Currency      string        `json:"currency"`
+	Services      int           `json:"services"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+type Discrepancy struct {
+	Service    string  `json:"service"`
+	Stored     float64 `json:"stored"`
+	Live       float64 `json:"live"`
+	Difference float64 `json:"difference"`
+}
+
+// Reconcile fetches live costs for the date range without storing them and
+// compares each service's total with what is stored for the same providers
+// and subscriptions. Services whose totals differ by more than tolerance are
+// reported, largest difference first; Difference is live minus stored. When
+// either side spans several currencies, both are converted to the target
+// currency before they are compared.
+func (s *Service) Reconcile(ctx context.Context, startDate, endDate string, tolerance float64) (*Reconciliation, error) {
+	// Totals per currency and then service, as GetAggregatedCostsByCurrency
+	// keys them
+	liveByCurrency := make(map[string]map[string]float64)
+	storedByCurrency := make(map[string]map[string]float64)
+	add := func(totals map[string]map[string]float64, currency, service string, amount float64) {
+		if currency == "" {
+			currency = "USD"
+		}
+		if totals[currency] == nil {
+			totals[currency] = make(map[string]float64)
+		}
+		totals[currency][service] += amount
+	}
+
+	for _, p := range s.providers {
+		granularity, err := s.providerGranularity(p)
+		if err != nil {
+			return nil, err
+		}
+		records, err := s.fetchProvider(ctx, p, granularity, startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			add(liveByCurrency, r.Currency, r.ServiceName, r.Cost)
+		}
+
+		byCurrency, err := s.db.GetAggregatedCostsByCurrency(storage.CostFilter{
+			StartDate:      startDate,
+			EndDate:        endDate,
+			Provider:       p.Name(),
+			SubscriptionID: p.Account(),
+			GroupBy:        "ServiceName",
+		})
+		if err != nil {
+			return nil, err
+		}
+		for currency, totals := range byCurrency {
+			for service, c := range totals {
+				add(storedByCurrency, currency, service, c)
+			}
+		}
+	}
+
+	currencies := make(map[string]bool)
+	for currency := range liveByCurrency {
+		currencies[currency] = true
+	}
+	for currency := range storedByCurrency {
+		currencies[currency] = true
+	}
+	mixed, err := s.mixedCurrencies(currencies)
+	if err != nil {
+		return nil, err
+	}
+	currency := s.targetCurrency
+	if !mixed {
+		for c := range currencies {
+			currency = c
+		}
+	}
+
+	live, err := s.convertTotals(liveByCurrency, mixed)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.convertTotals(storedByCurrency, mixed)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make(map[string]bool)
+	for name := range live {
+		services[name] = true
+	}
+	for name := range stored {
+		services[name] = true
+	}
+
+	result := &Reconciliation{
+		Period:        startDate + " to " + endDate,
+		Tolerance:     tolerance,
+		Currency:      currency,
+		Services:      len(services),
+		Discrepancies: []Discrepancy{},
+	}
+	for name := range services {
+		result.StoredTotal += stored[name]
+		result.LiveTotal += live[name]
+
+		diff := math.Round((live[name]-stored[name])*100) / 100
+		if math.Abs(diff) <= tolerance {
+			continue
+		}
+		result.Discrepancies = append(result.Discrepancies, Discrepancy{
+			Service:    name,
+			Stored:     math.Round(stored[name]*100) / 100,
+			Live:       math.Round(live[name]*100) / 100,
+			Difference: diff,
+		})
+	}
+	result.StoredTotal = math.Round(result.StoredTotal*100) / 100
+	result.LiveTotal = math.Round(result.LiveTotal*100) / 100
+
+	sort.Slice(result.Discrepancies, func(i, j int) bool {
+		return math.Abs(result.Discrepancies[i].Difference) > math.Abs(result.Discrepancies[j].Difference)
+	})
+	return result, nil
+}
+
+// convertTotals merges per-currency totals into one map, converting each
+// amount to the target currency when convert is set.
+func (s *Service) convertTotals(byCurrency map[string]map[string]float64, convert bool) (map[string]float64, error) {
+	result := make(map[string]float64)
+	for currency, totals := range byCurrency {
+		for name, amount := range totals {
+			if convert {
+				converted, err := s.toTargetCurrency(amount, currency)
+				if err != nil {
+					return nil, err
+				}
+				amount = converted
+			}
+			result[name] += amount
+		}
+	}
+	return result, nil
+}
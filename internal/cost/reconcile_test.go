@@ -0,0 +1,52 @@
+package cost
+
+import (
+	"context"
+	"testing"
+
+	"github.com/azguard/azguard/internal/storage"
+)
+
+func TestReconcileReportsDifferingService(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.SaveCostRecords([]storage.CostRecord{
+		costRecord("2026-09-01", "Storage", "USD", 10),
+		costRecord("2026-09-01", "Functions", "USD", 5),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProvider{account: "sub-1", records: []storage.CostRecord{
+		costRecord("2026-09-01", "Storage", "USD", 10),
+		costRecord("2026-09-01", "Functions", "USD", 7.5),
+	}}
+
+	result, err := NewService(db, p).Reconcile(context.Background(), "2026-09-01", "2026-09-30", 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Services != 2 || result.StoredTotal != 15 || result.LiveTotal != 17.5 {
+		t.Errorf("got %d services, stored %.2f, live %.2f", result.Services, result.StoredTotal, result.LiveTotal)
+	}
+	want := []Discrepancy{{Service: "Functions", Stored: 5, Live: 7.5, Difference: 2.5}}
+	if len(result.Discrepancies) != 1 || result.Discrepancies[0] != want[0] {
+		t.Errorf("discrepancies = %+v, want %+v", result.Discrepancies, want)
+	}
+}
+
+func TestReconcileConvertsCurrencies(t *testing.T) {
+	svc := newMixedCurrencyService(t, []storage.CostRecord{
+		costRecord("2026-09-01", "Storage", "USD", 20),
+	})
+	// The live side bills in EUR, worth two dollars each
+	svc.providers = []CloudCostProvider{&fakeProvider{account: "sub-1", records: []storage.CostRecord{
+		costRecord("2026-09-01", "Storage", "EUR", 10),
+	}}}
+
+	result, err := svc.Reconcile(context.Background(), "2026-09-01", "2026-09-30", 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Currency != "USD" || result.LiveTotal != 20 || len(result.Discrepancies) != 0 {
+		t.Errorf("got live %.2f %s with discrepancies %+v, want 20.00 USD and none", result.LiveTotal, result.Currency, result.Discrepancies)
+	}
+}
@@ -0,0 +1,111 @@
+package cost
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/azguard/azguard/internal/notify"
+)
+
+// reportTemplate is self-contained so the file can be opened or printed to PDF
+// without any other assets.
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"money": FormatMoney,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Cost Report - {{.Period}}</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; margin: 2rem auto; max-width: 800px; }
+  h1 { font-size: 1.6rem; margin-bottom: 0.2rem; }
+  h2 { font-size: 1.2rem; margin-top: 2rem; border-bottom: 1px solid #d0d7de; padding-bottom: 0.3rem; }
+  .meta { color: #656d76; font-size: 0.9rem; }
+  .cards { display: flex; gap: 1rem; margin-top: 1.5rem; }
+  .card { flex: 1; border: 1px solid #d0d7de; border-radius: 6px; padding: 1rem; }
+  .card .label { color: #656d76; font-size: 0.8rem; text-transform: uppercase; }
+  .card .value { font-size: 1.4rem; font-weight: 600; }
+  table { border-collapse: collapse; width: 100%; margin-top: 0.5rem; }
+  th, td { border: 1px solid #d0d7de; padding: 0.4rem 0.7rem; text-align: left; }
+  th { background: #f6f8fa; }
+  td.num, th.num { text-align: right; font-variant-numeric: tabular-nums; }
+  tr.total td { font-weight: 600; }
+  @media print { body { margin: 0; } .card, table { break-inside: avoid; } }
+</style>
+</head>
+<body>
+<h1>Cost Report</h1>
+<div class="meta">{{.Period}} · generated {{.GeneratedAt}}</div>
+
+<div class="cards">
+  <div class="card"><div class="label">Total</div><div class="value">{{money .TotalCost .Currency}}</div></div>
+  <div class="card"><div class="label">Next month forecast</div><div class="value">{{money .Forecast .Currency}}</div></div>
+</div>
+
+<h2>Top Services</h2>
+<table>
+  <tr><th>Service</th><th class="num">Cost</th></tr>
+  {{- range .TopServices}}
+  <tr><td>{{.Service}}</td><td class="num">{{money .Cost $.Currency}}</td></tr>
+  {{- end}}
+  <tr class="total"><td>Total</td><td class="num">{{money .TotalCost .Currency}}</td></tr>
+</table>
+
+<h2>Monthly Breakdown</h2>
+<table>
+  <tr><th>Month</th><th class="num">Cost</th></tr>
+  {{- range .MonthlyData}}
+  <tr><td>{{.Month}}</td><td class="num">{{money .TotalCost .Currency}}</td></tr>
+  {{- end}}
+</table>
+</body>
+</html>
+`))
+
+// GenerateHTMLReport renders the 12-month report as a standalone HTML page
+// with inline styles, suitable for printing to PDF.
+func (s *Service) GenerateHTMLReport(topN int) (string, error) {
+	report, err := s.GenerateReport(topN)
+	if err != nil {
+		return "", err
+	}
+	return RenderHTMLReport(report)
+}
+
+// RenderHTMLReport renders an already generated report as HTML.
+func RenderHTMLReport(report *Report) (string, error) {
+	var b strings.Builder
+	if err := reportTemplate.Execute(&b, report); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// EmailReport generates the report and mails it to the recipients, as HTML
+// or as Markdown text.
+func (s *Service) EmailReport(to []string, html bool, topN int) error {
+	if s.emailSender == nil {
+		return fmt.Errorf("email is not configured; set email.smtp_host and email.from")
+	}
+
+	report, err := s.GenerateReport(topN)
+	if err != nil {
+		return err
+	}
+
+	body := RenderMarkdownReport(report)
+	if html {
+		body, err = RenderHTMLReport(report)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.emailSender.Send(notify.EmailMessage{
+		To:      to,
+		Subject: fmt.Sprintf("azguard cost report - %s: %s", report.Period, FormatMoney(report.TotalCost, report.Currency)),
+		Body:    body,
+		HTML:    html,
+	})
+}
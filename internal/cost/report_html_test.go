@@ -0,0 +1,59 @@
+package cost
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/azguard/azguard/internal/notify"
+)
+
+func TestRenderHTMLReportShowsKeyValues(t *testing.T) {
+	report := testReport()
+	report.TopServices = append(report.TopServices, ServiceCost{Service: "<script>alert(1)</script>", Cost: 1})
+
+	html, err := RenderHTMLReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<title>Cost Report - Last 12 months</title>",
+		`<div class="value">€1,234.50</div>`,
+		`<div class="value">€150.00</div>`,
+		`<tr><td>Virtual Machines</td><td class="num">€1,000.00</td></tr>`,
+		`<tr class="total"><td>Total</td><td class="num">€1,234.50</td></tr>`,
+		`<tr><td>2026-09</td><td class="num">€634.50</td></tr>`,
+		"&lt;script&gt;",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML is missing %q", want)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Error("service names must be escaped")
+	}
+}
+
+type recordingSender struct {
+	messages []notify.EmailMessage
+}
+
+func (s *recordingSender) Send(msg notify.EmailMessage) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func TestEmailReportSendsHTML(t *testing.T) {
+	svc := NewService(newTestDB(t))
+	if err := svc.EmailReport([]string{"finance@example.com"}, true, 5); err == nil {
+		t.Error("expected an error when email isn't configured")
+	}
+
+	sender := &recordingSender{}
+	svc.SetEmailSender(sender)
+	if err := svc.EmailReport([]string{"finance@example.com"}, true, 5); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.messages) != 1 || !sender.messages[0].HTML || !strings.HasPrefix(sender.messages[0].Body, "<!DOCTYPE html>") {
+		t.Errorf("sent %+v, want one HTML report", sender.messages)
+	}
+}
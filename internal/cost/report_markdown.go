@@ -0,0 +1,37 @@
+package cost
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderMarkdownReport renders a report as GitHub-flavored Markdown.
+func RenderMarkdownReport(report *Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Cost Report\n\n")
+	fmt.Fprintf(&b, "- **Period:** %s\n", report.Period)
+	fmt.Fprintf(&b, "- **Generated:** %s\n", report.GeneratedAt)
+	fmt.Fprintf(&b, "- **Total:** %s\n", FormatMoney(report.TotalCost, report.Currency))
+	fmt.Fprintf(&b, "- **Next month forecast:** %s\n", FormatMoney(report.Forecast, report.Currency))
+
+	fmt.Fprintf(&b, "\n## Services\n\n")
+	fmt.Fprintf(&b, "| Service | Cost |\n")
+	fmt.Fprintf(&b, "|---|---:|\n")
+	for _, svc := range report.TopServices {
+		fmt.Fprintf(&b, "| %s | %s |\n", markdownEscape(svc.Service), FormatMoney(svc.Cost, report.Currency))
+	}
+	fmt.Fprintf(&b, "| **Total** | **%s** |\n", FormatMoney(report.TotalCost, report.Currency))
+
+	fmt.Fprintf(&b, "\n## Monthly Breakdown\n\n")
+	fmt.Fprintf(&b, "| Month | Cost |\n")
+	fmt.Fprintf(&b, "|---|---:|\n")
+	for _, m := range report.MonthlyData {
+		fmt.Fprintf(&b, "| %s | %s |\n", m.Month, FormatMoney(m.TotalCost, m.Currency))
+	}
+	return b.String()
+}
+
+// markdownEscape keeps service names containing pipes from breaking a table row.
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
@@ -0,0 +1,60 @@
+package cost
+
+import (
+	"strings"
+	"testing"
+)
+
+func testReport() *Report {
+	return &Report{
+		GeneratedAt: "2026-10-14T09:00:00Z",
+		Period:      "Last 12 months",
+		TotalCost:   1234.5,
+		Currency:    "EUR",
+		Forecast:    150,
+		TopServices: []ServiceCost{
+			{Service: "Virtual Machines", Cost: 1000, Percent: 81},
+			{Service: "Storage | Blob", Cost: 234.5, Percent: 19},
+		},
+		MonthlyData: []MonthlyReport{
+			{Month: "2026-10", TotalCost: 600, Currency: "EUR"},
+			{Month: "2026-09", TotalCost: 634.5, Currency: "EUR"},
+		},
+	}
+}
+
+func TestRenderMarkdownReportTables(t *testing.T) {
+	md := RenderMarkdownReport(testReport())
+
+	for _, want := range []string{
+		"- **Total:** €1,234.50\n",
+		"- **Next month forecast:** €150.00\n",
+		"## Services\n\n| Service | Cost |\n|---|---:|\n" +
+			"| Virtual Machines | €1,000.00 |\n" +
+			"| Storage \\| Blob | €234.50 |\n" +
+			"| **Total** | **€1,234.50** |\n",
+		"## Monthly Breakdown\n\n| Month | Cost |\n|---|---:|\n" +
+			"| 2026-10 | €600.00 |\n" +
+			"| 2026-09 | €634.50 |\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("report is missing %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "$") {
+		t.Errorf("EUR report contains a dollar sign:\n%s", md)
+	}
+}
+
+func TestRenderMarkdownReportRowsHaveTwoCells(t *testing.T) {
+	md := RenderMarkdownReport(testReport())
+	for _, line := range strings.Split(md, "\n") {
+		if !strings.HasPrefix(line, "|") {
+			continue
+		}
+		cells := strings.Count(strings.ReplaceAll(line, "\\|", ""), "|") - 1
+		if cells != 2 {
+			t.Errorf("row %q has %d cells, want 2", line, cells)
+		}
+	}
+}
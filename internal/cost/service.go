@@ -2,66 +2,331 @@ package cost
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/azguard/azguard/internal/cloud/azure"
+	"github.com/azguard/azguard/internal/notify"
 	"github.com/azguard/azguard/internal/storage"
 )
 
 type Service struct {
-	db        *storage.DB
-	azureCost *azure.CostClient
+	db             *storage.DB
+	providers      []CloudCostProvider
+	converter      CurrencyConverter
+	targetCurrency string
+	freeTierPath   string
+	notifier       notify.Notifier
+	emailSender    notify.EmailSender
+
+	cache       *resultCache
+	breakers    *breakerSet
+	progress    func(step string)
+	granularity string
+
+	billingCycleStartDay int
 }
 
-func NewService(db *storage.DB, azureCost *azure.CostClient) *Service {
+// NewService returns a service that fetches from the given providers. The
+// first provider also supplies API forecasts.
+func NewService(db *storage.DB, providers ...CloudCostProvider) *Service {
 	return &Service{
-		db:        db,
-		azureCost: azureCost,
+		db:             db,
+		providers:      providers,
+		targetCurrency: "USD",
+		cache:          newResultCache(DefaultCacheTTL),
+		breakers:       newBreakerSet(DefaultBreakerThreshold, DefaultBreakerCooldown),
+		granularity:    GranularityAuto,
+
+		billingCycleStartDay: 1,
 	}
 }
 
-func (s *Service) FetchAndStoreCosts(ctx context.Context, startDate, endDate string) error {
-	result, err := s.azureCost.QueryCostsByService(ctx, startDate, endDate)
+// SetCurrencyConverter sets the converter and target currency used when
+// stored records are billed in more than one currency.
+func (s *Service) SetCurrencyConverter(converter CurrencyConverter, targetCurrency string) {
+	s.converter = converter
+	if targetCurrency != "" {
+		s.targetCurrency = strings.ToUpper(targetCurrency)
+	}
+}
+
+// SetFreeTierPath sets the free tier limits file CheckFreeTier reads. An
+// empty path uses the default locations.
+func (s *Service) SetFreeTierPath(path string) {
+	s.freeTierPath = path
+}
+
+// SetBillingCycleStartDay sets the day of the month billing periods start
+// on, as accepted by GetCurrentBillingPeriod.
+func (s *Service) SetBillingCycleStartDay(day int) {
+	s.billingCycleStartDay = day
+}
+
+// TargetCurrency returns the currency that amounts in several currencies
+// are converted to, which budgets are also expressed in.
+func (s *Service) TargetCurrency() string {
+	return s.targetCurrency
+}
+
+// SetNotifier sets where CheckAlerts sends triggered-alert notifications.
+func (s *Service) SetNotifier(notifier notify.Notifier) {
+	s.notifier = notifier
+}
+
+// SetEmailSender sets how EmailReport delivers reports.
+func (s *Service) SetEmailSender(sender notify.EmailSender) {
+	s.emailSender = sender
+}
+
+// SetCircuitBreaker sets how many consecutive transient failures stop calls
+// to a provider's API, and for how long. A threshold of zero disables the
+// breakers.
+func (s *Service) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	s.breakers.configure(threshold, cooldown)
+}
+
+// SetProgress sets a function that is told about each step of multi-step
+// operations such as GetCurrentCosts, for showing progress.
+func (s *Service) SetProgress(progress func(step string)) {
+	s.progress = progress
+}
+
+func (s *Service) reportProgress(format string, args ...interface{}) {
+	if s.progress != nil {
+		s.progress(fmt.Sprintf(format, args...))
+	}
+}
+
+// SetFetchGranularity sets whether fetches store costs per service or,
+// with GranularityResource, per resource and meter. GranularityAuto, the
+// default, keeps each subscription at the granularity it was last fetched at.
+// Fetching a day replaces whatever was stored for it at either granularity.
+func (s *Service) SetFetchGranularity(granularity string) error {
+	switch granularity {
+	case GranularityAuto, GranularityService, GranularityResource:
+		s.granularity = granularity
+		return nil
+	default:
+		return fmt.Errorf("unknown fetch granularity %q (use %s, %s or %s)", granularity, GranularityAuto, GranularityService, GranularityResource)
+	}
+}
+
+// providerGranularity returns the granularity to fetch p at. In auto mode a
+// subscription last fetched per resource stays that way, so routine fetches
+// such as budget checks don't replace its detail with service totals.
+func (s *Service) providerGranularity(p CloudCostProvider) (string, error) {
+	if s.granularity != GranularityAuto {
+		return s.granularity, nil
+	}
+	if _, ok := p.(ResourceCostProvider); ok {
+		state, err := s.db.GetFetchState(p.Name(), p.Account(), GranularityResource)
+		if err != nil {
+			return "", fmt.Errorf("failed to read fetch state: %w", err)
+		}
+		if state.LastDate != "" {
+			return GranularityResource, nil
+		}
+	}
+	return GranularityService, nil
+}
+
+// SetCacheTTL sets how long GetTrendAnalysis and GetLocalForecast reuse a
+// result. Zero disables caching.
+func (s *Service) SetCacheTTL(ttl time.Duration) {
+	s.cache.setTTL(ttl)
+}
+
+// FetchCosts queries every provider for the date range and returns the
+// records that FetchAndStoreCosts would save, without touching the database.
+func (s *Service) FetchCosts(ctx context.Context, startDate, endDate string) ([]storage.CostRecord, error) {
+	var records []storage.CostRecord
+	for _, p := range s.providers {
+		granularity, err := s.providerGranularity(p)
+		if err != nil {
+			return nil, err
+		}
+		fetched, err := s.fetchProvider(ctx, p, granularity, startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, fetched...)
+	}
+	return records, nil
+}
+
+func (s *Service) fetchProvider(ctx context.Context, p CloudCostProvider, granularity, startDate, endDate string) ([]storage.CostRecord, error) {
+	query := p.QueryCostsByService
+	if granularity == GranularityResource {
+		rp, ok := p.(ResourceCostProvider)
+		if !ok {
+			return nil, fmt.Errorf("%s does not support resource granularity", p.Name())
+		}
+		query = rp.QueryCostsByResource
+	}
+
+	s.reportProgress("Fetching %s costs (%s to %s)", p.Name(), startDate, endDate)
+	var fetched []storage.CostRecord
+	err := s.breakers.get(p.Name()).call(func() error {
+		var err error
+		fetched, err = query(ctx, startDate, endDate)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to query costs: %w", err)
-	}
-
-	records := make([]storage.CostRecord, len(result.Records))
-	for i, r := range result.Records {
-		records[i] = storage.CostRecord{
-			SubscriptionID: s.azureCost.SubscriptionID,
-			ResourceGroup:  r.ResourceGroup,
-			ServiceName:    r.ServiceName,
-			Cost:           r.Cost,
-			Currency:       r.Currency,
-			Date:           r.Date,
+		return nil, fmt.Errorf("failed to query %s costs: %w", p.Name(), err)
+	}
+	slog.Debug("fetched costs", "provider", p.Name(), "start", startDate, "end", endDate, "records", len(fetched))
+	return fetched, nil
+}
+
+// AddProvider adds another provider for FetchCosts to query.
+func (s *Service) AddProvider(p CloudCostProvider) {
+	s.providers = append(s.providers, p)
+}
+
+// fetchSettleDays is how many of the most recently fetched days are fetched
+// again, because a provider may still revise their costs.
+const fetchSettleDays = 2
+
+// FetchAndStoreCosts fetches and saves costs for the date range. When the
+// range continues on from what was last fetched for a provider, only the
+// new days and the last fetchSettleDays fetched days, which may be before
+// startDate, are requested. Use FetchAndStoreAllCosts to re-fetch the whole
+// range.
+func (s *Service) FetchAndStoreCosts(ctx context.Context, startDate, endDate string) error {
+	return s.fetchAndStore(ctx, startDate, endDate, false)
+}
+
+// FetchAndStoreAllCosts fetches and saves the whole date range regardless of
+// what has been fetched before.
+func (s *Service) FetchAndStoreAllCosts(ctx context.Context, startDate, endDate string) error {
+	return s.fetchAndStore(ctx, startDate, endDate, true)
+}
+
+func (s *Service) fetchAndStore(ctx context.Context, startDate, endDate string, full bool) error {
+	type fetchedRange struct {
+		provider    CloudCostProvider
+		granularity string
+		state       storage.FetchState
+		startDate   string
+	}
+
+	var records []storage.CostRecord
+	var fetched []fetchedRange
+	var ranges []storage.CostRange
+	for _, p := range s.providers {
+		granularity, err := s.providerGranularity(p)
+		if err != nil {
+			return err
+		}
+		state, err := s.db.GetFetchState(p.Name(), p.Account(), granularity)
+		if err != nil {
+			return fmt.Errorf("failed to read fetch state: %w", err)
+		}
+
+		start := startDate
+		if !full {
+			start = incrementalStart(state, startDate, endDate)
+		}
+
+		providerRecords, err := s.fetchProvider(ctx, p, granularity, start, endDate)
+		if err != nil {
+			return err
 		}
+		records = append(records, providerRecords...)
+		fetched = append(fetched, fetchedRange{provider: p, granularity: granularity, state: state, startDate: start})
+		ranges = append(ranges, storage.CostRange{Provider: p.Name(), SubscriptionID: p.Account(), StartDate: start, EndDate: endDate})
 	}
 
-	if err := s.db.SaveCostRecords(records); err != nil {
+	s.reportProgress("Storing %d cost records", len(records))
+	if err := s.db.ReplaceCostRecords(ranges, records); err != nil {
 		return fmt.Errorf("failed to save cost records: %w", err)
 	}
 
+	// Days after today have no costs yet, so they don't count as fetched
+	lastDate := time.Now().Format("2006-01-02")
+	if endDate < lastDate {
+		lastDate = endDate
+	}
+	for _, r := range fetched {
+		name, account := r.provider.Name(), r.provider.Account()
+		state := mergeFetchState(r.state, r.startDate, lastDate)
+		if err := s.db.SetFetchState(name, account, r.granularity, state); err != nil {
+			return fmt.Errorf("failed to save fetch state: %w", err)
+		}
+
+		// Auto mode follows the granularity fetched last
+		other := GranularityResource
+		if r.granularity == GranularityResource {
+			other = GranularityService
+		}
+		if err := s.db.DeleteFetchState(name, account, other); err != nil {
+			return fmt.Errorf("failed to save fetch state: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// incrementalStart returns where a fetch of startDate to endDate should begin
+// given what has already been fetched. Only a range that picks up where the
+// fetched days leave off is shortened; any other range is fetched in full.
+func incrementalStart(state storage.FetchState, startDate, endDate string) string {
+	if state.LastDate == "" {
+		return startDate
+	}
+	next := addDays(state.LastDate, 1)
+	if startDate < state.FirstDate || startDate > next || next > endDate {
+		return startDate
+	}
+
+	start := addDays(state.LastDate, 1-fetchSettleDays)
+	if start < state.FirstDate {
+		start = state.FirstDate
+	}
+	return start
+}
+
+// mergeFetchState adds the days startDate to lastDate to the fetched range.
+// A range that doesn't touch the recorded one replaces it, so the state
+// never claims days in between were fetched.
+func mergeFetchState(state storage.FetchState, startDate, lastDate string) storage.FetchState {
+	if lastDate < startDate {
+		return state
+	}
+	if state.LastDate == "" || startDate > addDays(state.LastDate, 1) || lastDate < addDays(state.FirstDate, -1) {
+		return storage.FetchState{FirstDate: startDate, LastDate: lastDate}
+	}
+	if startDate < state.FirstDate {
+		state.FirstDate = startDate
+	}
+	if lastDate > state.LastDate {
+		state.LastDate = lastDate
+	}
+	return state
+}
+
+// addDays shifts a YYYY-MM-DD date, returning it unchanged if it can't be
+// parsed.
+func addDays(date string, days int) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return t.AddDate(0, 0, days).Format("2006-01-02")
+}
+
 func (s *Service) GetCostSummary(filter CostFilter) (*CostSummary, error) {
-	byService, err := s.db.GetAggregatedCosts(storage.CostFilter{
-		StartDate: filter.StartDate,
-		EndDate:   filter.EndDate,
-		GroupBy:   "ServiceName",
-	})
+	byService, currency, err := s.aggregateCosts(filter.storageFilter("ServiceName"))
 	if err != nil {
 		return nil, err
 	}
 
-	byResourceGroup, err := s.db.GetAggregatedCosts(storage.CostFilter{
-		StartDate: filter.StartDate,
-		EndDate:   filter.EndDate,
-		GroupBy:   "ResourceGroup",
-	})
+	byResourceGroup, _, err := s.aggregateCosts(filter.storageFilter("ResourceGroup"))
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +339,7 @@ func (s *Service) GetCostSummary(filter CostFilter) (*CostSummary, error) {
 	summary := &CostSummary{
 		Period:           filter.StartDate + " to " + filter.EndDate,
 		TotalCost:        totalCost,
-		Currency:         "USD",
+		Currency:         currency,
 		ByService:        byService,
 		ByResourceGroup: byResourceGroup,
 	}
@@ -82,33 +347,273 @@ func (s *Service) GetCostSummary(filter CostFilter) (*CostSummary, error) {
 	return summary, nil
 }
 
-func (s *Service) GetForecast(ctx context.Context) (*Forecast, error) {
-	localForecast, err := s.GetLocalForecast()
+// GetMultiCloudSummary totals this month's stored costs per provider, so
+// Azure, AWS and GCP spend can be read as one figure.
+func (s *Service) GetMultiCloudSummary(ctx context.Context) (*MultiCloudSummary, error) {
+	startDate, endDate := GetCurrentMonthDateRange()
+	perProvider, currency, err := s.aggregateCosts(storage.CostFilter{
+		StartDate: startDate,
+		EndDate:   endDate,
+		GroupBy:   "Provider",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var grandTotal float64
+	for _, c := range perProvider {
+		grandTotal += c
+	}
+
+	return &MultiCloudSummary{
+		Period:      startDate + " to " + endDate,
+		PerProvider: perProvider,
+		GrandTotal:  math.Round(grandTotal*100) / 100,
+		Currency:    currency,
+	}, nil
+}
+
+// GetDailyCosts returns the filtered spend per day, oldest first. Days billed
+// in several currencies are converted to the target currency.
+func (s *Service) GetDailyCosts(filter CostFilter) ([]DailyCost, error) {
+	rows, err := s.db.GetDailyCosts(filter.storageFilter(""))
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := make(map[string]bool)
+	for _, r := range rows {
+		currencies[r.Currency] = true
+	}
+	mixed, err := s.mixedCurrencies(currencies)
+	if err != nil {
+		return nil, err
+	}
+
+	var daily []DailyCost
+	for _, r := range rows {
+		amount, currency := r.TotalCost, r.Currency
+		if mixed {
+			if amount, err = s.toTargetCurrency(r.TotalCost, r.Currency); err != nil {
+				return nil, err
+			}
+			currency = s.targetCurrency
+		}
+
+		// Rows arrive ordered by date, so a day's currencies are adjacent
+		if n := len(daily); n > 0 && daily[n-1].Date == r.Date {
+			daily[n-1].TotalCost += amount
+			continue
+		}
+		daily = append(daily, DailyCost{Date: r.Date, TotalCost: amount, Currency: currency})
+	}
+	return daily, nil
+}
+
+// aggregateCosts sums stored costs per group. When the records span more than
+// one currency, every amount is converted to the target currency first.
+func (s *Service) aggregateCosts(filter storage.CostFilter) (map[string]float64, string, error) {
+	byCurrency, err := s.db.GetAggregatedCostsByCurrency(filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch len(byCurrency) {
+	case 0:
+		return map[string]float64{}, s.targetCurrency, nil
+	case 1:
+		for currency, totals := range byCurrency {
+			return totals, currency, nil
+		}
+	}
+
+	if s.converter == nil {
+		return nil, "", errMixedCurrencies(s.targetCurrency)
+	}
+
+	result, err := s.convertTotals(byCurrency, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return result, s.targetCurrency, nil
+}
+
+// monthlyCosts totals the filtered costs per month, newest first. Months
+// billed in several currencies are converted to the target currency.
+func (s *Service) monthlyCosts(months int, filter storage.CostFilter) ([]storage.MonthlyCost, error) {
+	rows, err := s.db.GetMonthlyCosts(months, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := make(map[string]bool)
+	for _, r := range rows {
+		currencies[r.Currency] = true
+	}
+	mixed, err := s.mixedCurrencies(currencies)
+	if err != nil {
+		return nil, err
+	}
+	if !mixed {
+		return rows, nil
+	}
+
+	var monthly []storage.MonthlyCost
+	for _, r := range rows {
+		amount, err := s.toTargetCurrency(r.TotalCost, r.Currency)
+		if err != nil {
+			return nil, err
+		}
+
+		// Rows arrive ordered by month, so a month's currencies are adjacent
+		if n := len(monthly); n > 0 && monthly[n-1].Month == r.Month {
+			monthly[n-1].TotalCost += amount
+			continue
+		}
+		monthly = append(monthly, storage.MonthlyCost{Month: r.Month, TotalCost: amount, Currency: s.targetCurrency})
+	}
+	return monthly, nil
+}
+
+// mixedCurrencies reports whether amounts in the given currencies have to be
+// converted before they are summed, failing when no converter is configured.
+func (s *Service) mixedCurrencies(currencies map[string]bool) (bool, error) {
+	if len(currencies) <= 1 {
+		return false, nil
+	}
+	if s.converter == nil {
+		return false, errMixedCurrencies(s.targetCurrency)
+	}
+	return true, nil
+}
+
+func (s *Service) toTargetCurrency(amount float64, currency string) (float64, error) {
+	converted, err := s.converter.Convert(amount, currency, s.targetCurrency)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert %s to %s: %w", currency, s.targetCurrency, err)
+	}
+	return converted, nil
+}
+
+// recordCosts returns each record's cost in one currency, along with that
+// currency. Records in several currencies are converted to the target one.
+func (s *Service) recordCosts(records []storage.CostRecord) ([]float64, string, error) {
+	currencies := make(map[string]bool)
+	for _, r := range records {
+		currencies[r.Currency] = true
+	}
+	mixed, err := s.mixedCurrencies(currencies)
+	if err != nil {
+		return nil, "", err
+	}
+
+	currency := s.targetCurrency
+	costs := make([]float64, len(records))
+	for i, r := range records {
+		costs[i] = r.Cost
+		if mixed {
+			if costs[i], err = s.toTargetCurrency(r.Cost, r.Currency); err != nil {
+				return nil, "", err
+			}
+		} else if r.Currency != "" {
+			currency = r.Currency
+		}
+	}
+	return costs, currency, nil
+}
+
+func errMixedCurrencies(target string) error {
+	return fmt.Errorf("cost records use multiple currencies; configure currency.rates to convert them to %s", target)
+}
+
+// maxForecastRetryWait caps how long GetForecast waits on a rate-limited
+// forecast request before retrying it.
+const maxForecastRetryWait = 10 * time.Second
+
+func (s *Service) GetForecast(ctx context.Context, method ForecastMethod) (*Forecast, error) {
+	localForecast, err := s.GetLocalForecast(method)
 	if err == nil && localForecast.Confidence != "low" {
 		return localForecast, nil
 	}
+	if len(s.providers) == 0 {
+		if localForecast != nil {
+			return localForecast, nil
+		}
+		return nil, err
+	}
+
+	provider := s.providers[0]
+	var nextMonth float64
+	var currency string
+	getForecast := func() error {
+		var err error
+		nextMonth, currency, err = provider.GetForecast(ctx, "Monthly")
+		return err
+	}
+	breaker := s.breakers.get(provider.Name())
+	err = breaker.call(getForecast)
+
+	// A short rate-limit back-off is worth waiting out once; anything else
+	// falls straight back to the local estimate
+	var reqErr *azure.RequestError
+	if errors.As(err, &reqErr) && errors.Is(err, azure.ErrRateLimited) &&
+		reqErr.RetryAfter > 0 && reqErr.RetryAfter <= maxForecastRetryWait {
+		slog.Info("forecast rate limited; retrying", "provider", provider.Name(), "after", reqErr.RetryAfter)
+		select {
+		case <-time.After(reqErr.RetryAfter):
+			err = breaker.call(getForecast)
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
 
-	result, err := s.azureCost.GetForecast(ctx, "Monthly")
 	if err != nil {
 		if localForecast != nil {
+			slog.Debug("provider forecast failed; using local forecast", "provider", provider.Name(), "err", err)
 			return localForecast, nil
 		}
+		if errors.Is(err, azure.ErrAuth) {
+			return nil, fmt.Errorf("no local cost history and Azure credentials were rejected (run 'az login' or check the azure auth settings): %w", err)
+		}
 		return nil, fmt.Errorf("both local and API forecast failed: %w", err)
 	}
 
 	return &Forecast{
-		NextMonth:  result.TotalCost,
+		NextMonth:  nextMonth,
 		Confidence: "medium",
+		Method:     ForecastAzure,
+		Currency:   currency,
 	}, nil
 }
 
+// GetCurrentCosts refreshes this month's costs from the cloud and summarizes
+// them. If the refresh fails but costs for the month are already stored, the
+// stored costs are summarized instead and Warning says how stale they are.
 func (s *Service) GetCurrentCosts(ctx context.Context) (*CostSummary, error) {
+	return s.getCurrentCosts(ctx, false)
+}
+
+// GetStoredCurrentCosts summarizes this month's stored costs without calling
+// the cloud provider, including for the forecast.
+func (s *Service) GetStoredCurrentCosts(ctx context.Context) (*CostSummary, error) {
+	return s.getCurrentCosts(ctx, true)
+}
+
+func (s *Service) getCurrentCosts(ctx context.Context, noFetch bool) (*CostSummary, error) {
 	startDate, endDate := GetCurrentMonthDateRange()
 
-	if err := s.FetchAndStoreCosts(ctx, startDate, endDate); err != nil {
-		return nil, err
+	var warning string
+	if !noFetch {
+		if err := s.FetchAndStoreCosts(ctx, startDate, endDate); err != nil {
+			latest, dbErr := s.db.LatestCostDate(storage.CostFilter{StartDate: startDate, EndDate: endDate})
+			if dbErr != nil || latest == "" {
+				return nil, err
+			}
+			warning = fmt.Sprintf("live fetch failed, showing stored costs through %s: %v", latest, err)
+		}
 	}
 
+	s.reportProgress("Summarizing costs")
 	summary, err := s.GetCostSummary(CostFilter{
 		StartDate: startDate,
 		EndDate:   endDate,
@@ -116,8 +621,15 @@ func (s *Service) GetCurrentCosts(ctx context.Context) (*CostSummary, error) {
 	if err != nil {
 		return nil, err
 	}
+	summary.Warning = warning
 
-	forecast, err := s.GetForecast(ctx)
+	s.reportProgress("Forecasting next month")
+	var forecast *Forecast
+	if noFetch {
+		forecast, err = s.GetLocalForecast(ForecastLinear)
+	} else {
+		forecast, err = s.GetForecast(ctx, ForecastLinear)
+	}
 	if err == nil {
 		summary.Forecast = forecast
 	}
@@ -127,16 +639,19 @@ func (s *Service) GetCurrentCosts(ctx context.Context) (*CostSummary, error) {
 
 func (s *Service) GetCostHistory(days int) (*CostSummary, error) {
 	startDate, endDate := GetLastNMonths(days)
+	return s.GetCostHistoryRange(CostFilter{StartDate: startDate, EndDate: endDate})
+}
 
-	summary, err := s.GetCostSummary(CostFilter{
-		StartDate: startDate,
-		EndDate:   endDate,
-	})
+// GetCostHistoryRange summarizes the filtered costs, with a monthly breakdown
+// of the last 12 months that fall inside the filter's date range. Setting
+// ServiceName charts a single service over time.
+func (s *Service) GetCostHistoryRange(filter CostFilter) (*CostSummary, error) {
+	summary, err := s.GetCostSummary(filter)
 	if err != nil {
 		return nil, err
 	}
 
-	monthlyCosts, err := s.db.GetMonthlyCosts(12)
+	monthlyCosts, err := s.monthlyCosts(12, filter.storageFilter(""))
 	if err == nil && len(monthlyCosts) > 0 {
 		summary.MonthlyBreakdown = monthlyCosts
 	}
@@ -144,6 +659,12 @@ func (s *Service) GetCostHistory(days int) (*CostSummary, error) {
 	return summary, nil
 }
 
+// GetWeeklyCosts totals the filtered costs per week over the last weeks
+// weeks, newest first.
+func (s *Service) GetWeeklyCosts(weeks int, filter CostFilter) ([]storage.WeeklyCost, error) {
+	return s.db.GetWeeklyCosts(weeks, filter.storageFilter(""))
+}
+
 type TrendAnalysis struct {
 	CurrentMonth    float64           `json:"current_month"`
 	PreviousMonth  float64           `json:"previous_month"`
@@ -151,10 +672,38 @@ type TrendAnalysis struct {
 	Trend          string            `json:"trend"`
 	AverageMonthly float64           `json:"average_monthly"`
 	Projection     float64           `json:"projection"`
+
+	Currency      string `json:"currency"`
+	ResourceGroup string `json:"resource_group,omitempty"`
 }
 
+// GetTrendAnalysis compares recent months. Results are cached for the cache
+// TTL or until cost records are next saved.
 func (s *Service) GetTrendAnalysis() (*TrendAnalysis, error) {
-	monthlyCosts, err := s.db.GetMonthlyCosts(6)
+	return s.GetTrendAnalysisByResourceGroup("")
+}
+
+// GetTrendAnalysisByResourceGroup is GetTrendAnalysis restricted to one
+// resource group; an empty rg covers all costs.
+func (s *Service) GetTrendAnalysisByResourceGroup(rg string) (*TrendAnalysis, error) {
+	// The month is part of the key because the analysis is anchored on it
+	key := "trend:" + rg + ":" + time.Now().Format("2006-01")
+	version := s.db.CostVersion()
+	if cached, ok := s.cache.get(key, version); ok {
+		trend := cached.(TrendAnalysis)
+		return &trend, nil
+	}
+
+	trend, err := s.computeTrendAnalysis(rg)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.set(key, version, *trend)
+	return trend, nil
+}
+
+func (s *Service) computeTrendAnalysis(rg string) (*TrendAnalysis, error) {
+	monthlyCosts, err := s.monthlyCosts(6, storage.CostFilter{ResourceGroup: rg})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get monthly costs: %w", err)
 	}
@@ -167,6 +716,8 @@ func (s *Service) GetTrendAnalysis() (*TrendAnalysis, error) {
 			Trend:         "no_data",
 			AverageMonthly: 0,
 			Projection:    0,
+			Currency:      s.targetCurrency,
+			ResourceGroup: rg,
 		}, nil
 	}
 
@@ -203,6 +754,8 @@ func (s *Service) GetTrendAnalysis() (*TrendAnalysis, error) {
 		Trend:          trend,
 		AverageMonthly: math.Round(averageMonthly*100) / 100,
 		Projection:     math.Round(projection*100) / 100,
+		Currency:       monthlyCosts[0].Currency,
+		ResourceGroup:  rg,
 	}, nil
 }
 
@@ -214,8 +767,10 @@ func (s *Service) calculateProjection(monthlyCosts []storage.MonthlyCost) float6
 	n := float64(len(monthlyCosts))
 	var sumX, sumY, sumXY, sumX2 float64
 
+	// monthlyCosts is newest first; x runs oldest to newest so the
+	// projection extends forward in time
 	for i, mc := range monthlyCosts {
-		x := float64(i)
+		x := n - 1 - float64(i)
 		y := mc.TotalCost
 		sumX += x
 		sumY += y
@@ -230,8 +785,60 @@ func (s *Service) calculateProjection(monthlyCosts []storage.MonthlyCost) float6
 	return slope*nextMonthIndex + intercept
 }
 
-func (s *Service) GetLocalForecast() (*Forecast, error) {
-	monthlyCosts, err := s.db.GetMonthlyCosts(6)
+// calculateMovingAverage averages the most recent months (newest first).
+func (s *Service) calculateMovingAverage(monthlyCosts []storage.MonthlyCost, months int) float64 {
+	if len(monthlyCosts) < months {
+		months = len(monthlyCosts)
+	}
+	if months == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, mc := range monthlyCosts[:months] {
+		sum += mc.TotalCost
+	}
+	return sum / float64(months)
+}
+
+// calculateExponentialSmoothing applies simple exponential smoothing from
+// the oldest month to the newest and returns the final smoothed level.
+func (s *Service) calculateExponentialSmoothing(monthlyCosts []storage.MonthlyCost, alpha float64) float64 {
+	if len(monthlyCosts) == 0 {
+		return 0
+	}
+
+	level := monthlyCosts[len(monthlyCosts)-1].TotalCost
+	for i := len(monthlyCosts) - 2; i >= 0; i-- {
+		level = alpha*monthlyCosts[i].TotalCost + (1-alpha)*level
+	}
+	return level
+}
+
+// GetLocalForecast projects next month from stored history. Results are
+// cached per method for the cache TTL or until cost records are next saved.
+func (s *Service) GetLocalForecast(method ForecastMethod) (*Forecast, error) {
+	if method == "" {
+		method = ForecastLinear
+	}
+
+	key := "forecast:" + string(method) + ":" + time.Now().Format("2006-01")
+	version := s.db.CostVersion()
+	if cached, ok := s.cache.get(key, version); ok {
+		forecast := cached.(Forecast)
+		return &forecast, nil
+	}
+
+	forecast, err := s.computeLocalForecast(method)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.set(key, version, *forecast)
+	return forecast, nil
+}
+
+func (s *Service) computeLocalForecast(method ForecastMethod) (*Forecast, error) {
+	monthlyCosts, err := s.monthlyCosts(6, storage.CostFilter{})
 	if err != nil {
 		return nil, err
 	}
@@ -240,10 +847,20 @@ func (s *Service) GetLocalForecast() (*Forecast, error) {
 		return &Forecast{
 			NextMonth:  0,
 			Confidence: "low",
+			Method:     method,
+			Currency:   s.targetCurrency,
 		}, nil
 	}
 
-	projection := s.calculateProjection(monthlyCosts)
+	var projection float64
+	switch method {
+	case ForecastMovingAverage:
+		projection = s.calculateMovingAverage(monthlyCosts, movingAverageMonths)
+	case ForecastExponential:
+		projection = s.calculateExponentialSmoothing(monthlyCosts, smoothingFactor)
+	default:
+		projection = s.calculateProjection(monthlyCosts)
+	}
 	if projection < 0 {
 		projection = 0
 	}
@@ -259,11 +876,32 @@ func (s *Service) GetLocalForecast() (*Forecast, error) {
 	return &Forecast{
 		NextMonth:  math.Round(projection*100) / 100,
 		Confidence: confidence,
+		Method:     method,
+		Currency:   monthlyCosts[0].Currency,
 	}, nil
 }
 
-func (s *Service) GenerateReport() (*Report, error) {
-	monthlyCosts, err := s.db.GetMonthlyCosts(12)
+// DefaultReportTopServices is how many services a report lists unless the
+// caller asks for a different number.
+const DefaultReportTopServices = 10
+
+// GetTopServices returns the n most expensive services in the filter range.
+func (s *Service) GetTopServices(filter CostFilter, n int) ([]ServiceCost, string, error) {
+	byService, currency, err := s.aggregateCosts(filter.storageFilter("ServiceName"))
+	if err != nil {
+		return nil, "", err
+	}
+	return TopServices(byService, n), currency, nil
+}
+
+// GenerateReport summarizes the last 12 months, listing the topN most
+// expensive services (DefaultReportTopServices when topN <= 0).
+func (s *Service) GenerateReport(topN int) (*Report, error) {
+	if topN <= 0 {
+		topN = DefaultReportTopServices
+	}
+
+	monthlyCosts, err := s.monthlyCosts(12, storage.CostFilter{})
 	if err != nil {
 		return nil, err
 	}
@@ -273,7 +911,7 @@ func (s *Service) GenerateReport() (*Report, error) {
 		return nil, err
 	}
 
-	forecast, _ := s.GetLocalForecast()
+	forecast, _ := s.GetLocalForecast(ForecastLinear)
 
 	var monthlyData []MonthlyReport
 	for _, m := range monthlyCosts {
@@ -284,13 +922,7 @@ func (s *Service) GenerateReport() (*Report, error) {
 		})
 	}
 
-	var topServices []ServiceCost
-	for service, cost := range summary.ByService {
-		topServices = append(topServices, ServiceCost{
-			Service: service,
-			Cost:    cost,
-		})
-	}
+	topServices := TopServices(summary.ByService, topN)
 
 	period := "Last 12 months"
 	if len(monthlyCosts) > 0 {
@@ -0,0 +1,490 @@
+package cost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azguard/azguard/internal/storage"
+)
+
+// fakeProvider records the ranges it is asked for and returns canned records
+// stamped with its account.
+type fakeProvider struct {
+	account  string
+	records  []storage.CostRecord
+	calls    [][2]string
+	forecast float64
+	// forecastCurrency defaults to USD
+	forecastCurrency string
+}
+
+func (f *fakeProvider) Name() string    { return "azure" }
+func (f *fakeProvider) Account() string { return f.account }
+
+func (f *fakeProvider) QueryCostsByService(ctx context.Context, startDate, endDate string) ([]storage.CostRecord, error) {
+	f.calls = append(f.calls, [2]string{startDate, endDate})
+	records := make([]storage.CostRecord, len(f.records))
+	for i, r := range f.records {
+		r.SubscriptionID = f.account
+		r.Provider = "azure"
+		records[i] = r
+	}
+	return records, nil
+}
+
+func (f *fakeProvider) GetForecast(ctx context.Context, granularity string) (float64, string, error) {
+	if f.forecastCurrency == "" {
+		return f.forecast, "USD", nil
+	}
+	return f.forecast, f.forecastCurrency, nil
+}
+
+// fakeResourceProvider also serves resource-level queries, recorded apart
+// from service-level ones.
+type fakeResourceProvider struct {
+	fakeProvider
+	resourceCalls [][2]string
+}
+
+func (f *fakeResourceProvider) QueryCostsByResource(ctx context.Context, startDate, endDate string) ([]storage.CostRecord, error) {
+	f.resourceCalls = append(f.resourceCalls, [2]string{startDate, endDate})
+	return nil, nil
+}
+
+func newTestDB(t *testing.T) *storage.DB {
+	t.Helper()
+	db, err := storage.New(filepath.Join(t.TempDir(), "azguard.db"))
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestFetchAndStoreCostsOnlyRequestsNewDays(t *testing.T) {
+	p := &fakeProvider{account: "sub-1"}
+	svc := NewService(newTestDB(t), p)
+	ctx := context.Background()
+	today := time.Now().Format("2006-01-02")
+
+	// An open-ended range, like the current month, so today is inside it
+	start, end := addDays(today, -20), addDays(today, 20)
+	if err := svc.FetchAndStoreCosts(ctx, start, end); err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	if err := svc.FetchAndStoreCosts(ctx, start, end); err != nil {
+		t.Fatalf("second fetch: %v", err)
+	}
+
+	if len(p.calls) != 2 {
+		t.Fatalf("got %d queries, want 2", len(p.calls))
+	}
+	if p.calls[0][0] != start {
+		t.Errorf("first fetch started at %s, want %s", p.calls[0][0], start)
+	}
+	// Only today and the settling day before it are requested again
+	if want := addDays(today, 1-fetchSettleDays); p.calls[1][0] != want {
+		t.Errorf("second fetch started at %s, want %s", p.calls[1][0], want)
+	}
+}
+
+func TestFetchStateIsKeptPerGranularity(t *testing.T) {
+	p := &fakeResourceProvider{fakeProvider: fakeProvider{account: "sub-1"}}
+	svc := NewService(newTestDB(t), p)
+	ctx := context.Background()
+	today := time.Now().Format("2006-01-02")
+	start, end := addDays(today, -20), addDays(today, 20)
+
+	if err := svc.FetchAndStoreCosts(ctx, start, end); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.SetFetchGranularity(GranularityResource); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.FetchAndStoreCosts(ctx, start, end); err != nil {
+		t.Fatal(err)
+	}
+
+	// The service-level fetch must not stop the first resource-level one
+	// from covering the whole range
+	if len(p.resourceCalls) != 1 || p.resourceCalls[0][0] != start {
+		t.Errorf("resource queries = %v, want one starting at %s", p.resourceCalls, start)
+	}
+}
+
+func TestFetchAndStoreCostsFetchesOlderRangesInFull(t *testing.T) {
+	p := &fakeProvider{account: "sub-1"}
+	svc := NewService(newTestDB(t), p)
+	ctx := context.Background()
+	today := time.Now().Format("2006-01-02")
+
+	if err := svc.FetchAndStoreCosts(ctx, addDays(today, -5), addDays(today, 5)); err != nil {
+		t.Fatal(err)
+	}
+	older, olderEnd := addDays(today, -60), addDays(today, -40)
+	if err := svc.FetchAndStoreCosts(ctx, older, olderEnd); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.calls[1]; got != [2]string{older, olderEnd} {
+		t.Errorf("older range fetched as %v, want %s to %s", got, older, olderEnd)
+	}
+}
+
+func TestFetchAndStoreAllCostsIgnoresState(t *testing.T) {
+	p := &fakeProvider{account: "sub-1"}
+	svc := NewService(newTestDB(t), p)
+	ctx := context.Background()
+	today := time.Now().Format("2006-01-02")
+	start, end := addDays(today, -10), addDays(today, 10)
+
+	for i := 0; i < 2; i++ {
+		if err := svc.FetchAndStoreAllCosts(ctx, start, end); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.calls[1][0] != start {
+		t.Errorf("full fetch started at %s, want %s", p.calls[1][0], start)
+	}
+}
+
+func TestIncrementalStartAcrossMonthRollover(t *testing.T) {
+	state := storage.FetchState{FirstDate: "2026-10-01", LastDate: "2026-10-31"}
+
+	// November's first fetch also re-fetches October's last settling days
+	if got := incrementalStart(state, "2026-11-01", "2026-12-01"); got != "2026-10-30" {
+		t.Errorf("incrementalStart = %s, want 2026-10-30", got)
+	}
+
+	merged := mergeFetchState(state, "2026-10-30", "2026-11-02")
+	if merged != (storage.FetchState{FirstDate: "2026-10-01", LastDate: "2026-11-02"}) {
+		t.Errorf("mergeFetchState = %+v", merged)
+	}
+}
+
+func TestMergeFetchStateReplacesDisjointRange(t *testing.T) {
+	state := storage.FetchState{FirstDate: "2026-08-01", LastDate: "2026-08-15"}
+
+	merged := mergeFetchState(state, "2026-10-01", "2026-10-14")
+	if merged != (storage.FetchState{FirstDate: "2026-10-01", LastDate: "2026-10-14"}) {
+		t.Errorf("mergeFetchState = %+v, want only the new range", merged)
+	}
+}
+
+func TestGetForecastFallsBackToProvider(t *testing.T) {
+	// With no stored history the API forecast is used, in the currency the
+	// subscription is billed in
+	svc := NewService(newTestDB(t), &fakeProvider{account: "sub-1", forecast: 120, forecastCurrency: "EUR"})
+
+	forecast, err := svc.GetForecast(context.Background(), ForecastLinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forecast.NextMonth != 120 || forecast.Method != ForecastAzure || forecast.Currency != "EUR" {
+		t.Errorf("forecast = %+v, want 120 EUR from %s", forecast, ForecastAzure)
+	}
+}
+
+func TestLocalForecastMethods(t *testing.T) {
+	db := newTestDB(t)
+	monthStart, _ := GetCurrentMonthDateRange()
+	first, _ := time.Parse("2006-01-02", monthStart)
+	// Oldest to newest, ending with this month
+	for i, c := range []float64{100, 110, 120, 130, 200} {
+		date := first.AddDate(0, i-4, 0).Format("2006-01-02")
+		if err := db.SaveCostRecord(storage.CostRecord{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: c, Currency: "USD", Date: date}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := NewService(db)
+
+	for method, want := range map[ForecastMethod]float64{
+		ForecastLinear:        198,    // slope 22 from an intercept of 88
+		ForecastMovingAverage: 150,    // (120 + 130 + 200) / 3
+		ForecastExponential:   160.63, // smoothed with alpha 0.5
+	} {
+		forecast, err := svc.GetLocalForecast(method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if forecast.NextMonth != want || forecast.Method != method || forecast.Confidence != "medium" {
+			t.Errorf("%s forecast = %+v, want %.2f with medium confidence", method, forecast, want)
+		}
+	}
+}
+
+func TestMultiCloudSummaryTotalsEachProvider(t *testing.T) {
+	monthStart, _ := GetCurrentMonthDateRange()
+	onProvider := func(provider string, r storage.CostRecord) storage.CostRecord {
+		r.Provider = provider
+		r.SubscriptionID = provider + "-account"
+		return r
+	}
+	svc := newMixedCurrencyService(t, []storage.CostRecord{
+		onProvider("azure", costRecord(monthStart, "Virtual Machines", "USD", 40)),
+		onProvider("azure", costRecord(monthStart, "Storage", "USD", 2.5)),
+		onProvider("aws", costRecord(monthStart, "Amazon EC2", "USD", 30)),
+		onProvider("gcp", costRecord(monthStart, "Compute Engine", "EUR", 10)),
+		// Last month's spend is outside the summary
+		onProvider("aws", costRecord(addDays(monthStart, -1), "Amazon EC2", "USD", 500)),
+	})
+
+	summary, err := svc.GetMultiCloudSummary(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]float64{"azure": 42.5, "aws": 30, "gcp": 20}
+	if len(summary.PerProvider) != len(want) {
+		t.Errorf("per provider = %v, want %v", summary.PerProvider, want)
+	}
+	for provider, cost := range want {
+		if summary.PerProvider[provider] != cost {
+			t.Errorf("%s = %v, want %v", provider, summary.PerProvider[provider], cost)
+		}
+	}
+	if summary.GrandTotal != 92.5 || summary.Currency != "USD" {
+		t.Errorf("grand total = %v %s, want 92.5 USD", summary.GrandTotal, summary.Currency)
+	}
+}
+
+func TestDailyCostsSumToSummaryTotal(t *testing.T) {
+	svc := newMixedCurrencyService(t, []storage.CostRecord{
+		costRecord("2026-09-01", "Virtual Machines", "USD", 4.25),
+		costRecord("2026-09-01", "Storage", "USD", 0.75),
+		costRecord("2026-09-02", "Virtual Machines", "EUR", 1.5),
+		costRecord("2026-09-02", "Storage", "USD", 1),
+		costRecord("2026-09-04", "Virtual Machines", "USD", 3),
+		// Outside the filtered range
+		costRecord("2026-10-01", "Virtual Machines", "USD", 50),
+	})
+	filter := CostFilter{StartDate: "2026-09-01", EndDate: "2026-09-30"}
+
+	daily, err := svc.GetDailyCosts(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := svc.GetCostSummary(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []DailyCost{
+		{Date: "2026-09-01", TotalCost: 5, Currency: "USD"},
+		{Date: "2026-09-02", TotalCost: 4, Currency: "USD"},
+		{Date: "2026-09-04", TotalCost: 3, Currency: "USD"},
+	}
+	if len(daily) != len(want) {
+		t.Fatalf("daily = %+v, want %+v", daily, want)
+	}
+	var sum float64
+	for i, d := range daily {
+		if d != want[i] {
+			t.Errorf("day %d = %+v, want %+v", i, d, want[i])
+		}
+		sum += d.TotalCost
+	}
+	if sum != summary.TotalCost {
+		t.Errorf("daily costs sum to %v, summary total is %v", sum, summary.TotalCost)
+	}
+}
+
+func TestGenerateReportTruncatesTopServices(t *testing.T) {
+	var records []storage.CostRecord
+	for i := 0; i < 12; i++ {
+		records = append(records, costRecord("2026-09-01", fmt.Sprintf("Service %02d", i), "USD", float64(i%4+1)))
+	}
+	svc := newMixedCurrencyService(t, records)
+
+	report, err := svc.GenerateReport(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Services 03, 07 and 11 all cost 4; ties are broken by name
+	want := []string{"Service 03", "Service 07", "Service 11"}
+	if len(report.TopServices) != len(want) {
+		t.Fatalf("top services = %+v, want %v", report.TopServices, want)
+	}
+	for i, name := range want {
+		if report.TopServices[i].Service != name {
+			t.Errorf("top service %d = %s, want %s", i, report.TopServices[i].Service, name)
+		}
+	}
+
+	report, err = svc.GenerateReport(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.TopServices) != DefaultReportTopServices {
+		t.Errorf("got %d top services, want the default %d", len(report.TopServices), DefaultReportTopServices)
+	}
+}
+
+func TestSubscriptionsAreFetchedAndFilteredSeparately(t *testing.T) {
+	db := newTestDB(t)
+	primary := &fakeProvider{account: "sub-a", records: []storage.CostRecord{
+		costRecord("2026-09-01", "Virtual Machines", "USD", 20),
+	}}
+	secondary := &fakeProvider{account: "sub-b", records: []storage.CostRecord{
+		costRecord("2026-09-01", "Virtual Machines", "USD", 5),
+		costRecord("2026-09-02", "Storage", "USD", 1),
+	}}
+	svc := NewService(db, primary)
+	svc.AddProvider(secondary)
+
+	if err := svc.FetchAndStoreCosts(context.Background(), "2026-09-01", "2026-09-30"); err != nil {
+		t.Fatal(err)
+	}
+	if len(primary.calls) != 1 || len(secondary.calls) != 1 {
+		t.Errorf("queried subscriptions %d and %d times, want once each", len(primary.calls), len(secondary.calls))
+	}
+
+	ids, err := db.GetSubscriptionIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ids, ",") != "sub-a,sub-b" {
+		t.Errorf("subscriptions = %v, want sub-a and sub-b", ids)
+	}
+
+	for sub, want := range map[string]float64{"sub-a": 20, "sub-b": 6, "": 26} {
+		summary, err := svc.GetCostSummary(CostFilter{StartDate: "2026-09-01", EndDate: "2026-09-30", SubscriptionID: sub})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.TotalCost != want {
+			t.Errorf("subscription %q total = %v, want %v", sub, summary.TotalCost, want)
+		}
+	}
+}
+
+func TestCurrentCostsFallBackToStoredRecords(t *testing.T) {
+	monthStart, _ := GetCurrentMonthDateRange()
+	p := &failingProvider{fakeProvider: fakeProvider{account: "sub-1"}, err: errors.New("connection refused")}
+	db := newTestDB(t)
+	if err := db.SaveCostRecord(costRecord(monthStart, "Storage", "USD", 7.5)); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(db, p)
+
+	summary, err := svc.GetCurrentCosts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.calls != 1 {
+		t.Errorf("provider queried %d times, want 1", p.calls)
+	}
+	if summary.TotalCost != 7.5 {
+		t.Errorf("total = %v, want the stored 7.5", summary.TotalCost)
+	}
+	want := "live fetch failed, showing stored costs through " + monthStart
+	if !strings.Contains(summary.Warning, want) || !strings.Contains(summary.Warning, "connection refused") {
+		t.Errorf("warning = %q, want %q and the fetch error", summary.Warning, want)
+	}
+}
+
+func TestCurrentCostsFailWithoutStoredRecords(t *testing.T) {
+	p := &failingProvider{fakeProvider: fakeProvider{account: "sub-1"}, err: errors.New("connection refused")}
+	svc := NewService(newTestDB(t), p)
+
+	if _, err := svc.GetCurrentCosts(context.Background()); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("err = %v, want the fetch error when nothing is stored", err)
+	}
+}
+
+func TestTrendAnalysisByResourceGroup(t *testing.T) {
+	thisMonth, _ := GetCurrentMonthDateRange()
+	lastMonth := addDays(thisMonth, -1)
+	inGroup := func(rg string, r storage.CostRecord) storage.CostRecord {
+		r.ResourceGroup = rg
+		return r
+	}
+	svc := newMixedCurrencyService(t, []storage.CostRecord{
+		inGroup("web-rg", costRecord(lastMonth, "Virtual Machines", "USD", 100)),
+		inGroup("web-rg", costRecord(thisMonth, "Virtual Machines", "USD", 150)),
+		inGroup("data-rg", costRecord(lastMonth, "Storage", "USD", 40)),
+		inGroup("data-rg", costRecord(thisMonth, "Storage", "USD", 20)),
+	})
+
+	for _, tt := range []struct {
+		rg                string
+		current, previous float64
+		trend             string
+	}{
+		{"web-rg", 150, 100, "increasing"},
+		{"data-rg", 20, 40, "decreasing"},
+		{"", 170, 140, "increasing"},
+		{"empty-rg", 0, 0, "no_data"},
+	} {
+		trend, err := svc.GetTrendAnalysisByResourceGroup(tt.rg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trend.CurrentMonth != tt.current || trend.PreviousMonth != tt.previous || trend.Trend != tt.trend || trend.ResourceGroup != tt.rg {
+			t.Errorf("%q trend = %+v, want %v after %v (%s)", tt.rg, trend, tt.current, tt.previous, tt.trend)
+		}
+	}
+}
+
+func TestCostHistoryForOneService(t *testing.T) {
+	thisMonth, _ := GetCurrentMonthDateRange()
+	lastMonth := addDays(thisMonth, -1)
+	svc := newMixedCurrencyService(t, []storage.CostRecord{
+		costRecord(lastMonth, "Virtual Machines", "USD", 30),
+		costRecord(thisMonth, "Virtual Machines", "USD", 45),
+		costRecord(lastMonth, "Storage", "USD", 5),
+		costRecord(thisMonth, "Storage", "USD", 6),
+	})
+
+	summary, err := svc.GetCostHistoryRange(CostFilter{StartDate: lastMonth[:8] + "01", EndDate: thisMonth, ServiceName: "Virtual Machines"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalCost != 75 || len(summary.ByService) != 1 {
+		t.Errorf("summary = %v over %v, want 75 from Virtual Machines only", summary.TotalCost, summary.ByService)
+	}
+
+	want := []storage.MonthlyCost{
+		{Month: thisMonth[:7], TotalCost: 45, Currency: "USD"},
+		{Month: lastMonth[:7], TotalCost: 30, Currency: "USD"},
+	}
+	if len(summary.MonthlyBreakdown) != len(want) {
+		t.Fatalf("monthly = %+v, want %+v", summary.MonthlyBreakdown, want)
+	}
+	for i := range want {
+		if summary.MonthlyBreakdown[i] != want[i] {
+			t.Errorf("month %d = %+v, want %+v", i, summary.MonthlyBreakdown[i], want[i])
+		}
+	}
+}
+
+func TestProgressReportsEachStep(t *testing.T) {
+	svc := NewService(newTestDB(t), &fakeProvider{account: "sub-1"})
+	var steps []string
+	svc.SetProgress(func(step string) { steps = append(steps, step) })
+
+	if _, err := svc.GetCurrentCosts(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	start, end := GetCurrentMonthDateRange()
+	want := []string{
+		"Fetching azure costs (" + start + " to " + end + ")",
+		"Summarizing costs",
+		"Forecasting next month",
+	}
+	for _, w := range want {
+		found := false
+		for _, step := range steps {
+			found = found || step == w
+		}
+		if !found {
+			t.Errorf("steps = %q, want %q among them", steps, w)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifierPostsEvent(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	event := Event{
+		Alert:       "budget-50",
+		Threshold:   50,
+		CurrentCost: 62.5,
+		Timestamp:   time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC),
+	}
+	if err := NewWebhookNotifier(server.URL).Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"alert":        "budget-50",
+		"threshold":    50.0,
+		"current_cost": 62.5,
+		"timestamp":    "2026-10-14T09:00:00Z",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("payload %s = %v, want %v", key, got[key], value)
+		}
+	}
+}
+
+func TestWebhookNotifierReportsFailedDelivery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if err := NewWebhookNotifier(server.URL).Notify(context.Background(), Event{Alert: "budget-50"}); err == nil {
+		t.Error("expected an error for a 500 response")
+	}
+}
+
+type recordingNotifier struct {
+	events []Event
+	err    error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, event Event) error {
+	n.events = append(n.events, event)
+	return n.err
+}
+
+func TestMultiContinuesPastFailures(t *testing.T) {
+	failing := &recordingNotifier{err: errors.New("unreachable")}
+	working := &recordingNotifier{}
+
+	err := Multi(failing, working).Notify(context.Background(), Event{Alert: "budget-50"})
+	if err == nil {
+		t.Error("expected the failing notifier's error")
+	}
+	if len(working.events) != 1 {
+		t.Errorf("second notifier got %d events, want 1", len(working.events))
+	}
+}
@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SlackNotifier posts events to a Slack incoming webhook as Block Kit
+// messages.
+type SlackNotifier struct {
+	WebhookURL string
+	client     *http.Client
+}
+
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{
+		WebhookURL: webhookURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, event Event) error {
+	return postJSON(ctx, n.client, n.WebhookURL, slackPayload(event))
+}
+
+// slackPayload builds the Block Kit message for an event. Text is the
+// plain fallback Slack shows in notifications.
+func slackPayload(event Event) slackMessage {
+	var percentUsed float64
+	if event.Threshold > 0 {
+		percentUsed = event.CurrentCost / event.Threshold * 100
+	}
+	emoji := severityEmoji(percentUsed)
+	headline := fmt.Sprintf("%s Budget alert '%s' triggered", emoji, event.Alert)
+
+	return slackMessage{
+		Text: headline,
+		Blocks: []slackBlock{
+			{
+				Type: "header",
+				Text: &slackText{Type: "plain_text", Text: headline},
+			},
+			{
+				Type: "section",
+				Fields: []slackText{
+					{Type: "mrkdwn", Text: "*Alert:*\n" + event.Alert},
+					{Type: "mrkdwn", Text: fmt.Sprintf("*Threshold:*\n$%.2f", event.Threshold)},
+					{Type: "mrkdwn", Text: fmt.Sprintf("*Current spend:*\n$%.2f", event.CurrentCost)},
+					{Type: "mrkdwn", Text: fmt.Sprintf("*Used:*\n%.0f%%", percentUsed)},
+				},
+			},
+			{
+				Type: "context",
+				Elements: []slackText{
+					{Type: "mrkdwn", Text: "azguard · " + event.Timestamp.Format(time.RFC1123)},
+				},
+			},
+		},
+	}
+}
+
+func severityEmoji(percentUsed float64) string {
+	switch {
+	case percentUsed >= 200:
+		return "🔥"
+	case percentUsed >= 150:
+		return "🚨"
+	default:
+		return "⚠️"
+	}
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// migration is one schema change. Versions are applied in order, each in its
+// own transaction, and recorded in schema_migrations so they run only once.
+type migration struct {
+	version     int
+	description string
+	apply       func(tx *sql.Tx) error
+}
+
+// migrations must only ever be appended to; never renumber or edit an entry
+// that has shipped. Every step is written so it also succeeds against
+// databases created before versioning existed.
+var migrations = []migration{
+	{1, "initial schema", execAll(
+		`CREATE TABLE IF NOT EXISTS config (
+			key TEXT PRIMARY KEY,
+			value TEXT,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS cost_records (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			subscription_id TEXT NOT NULL,
+			resource_group TEXT,
+			service_name TEXT NOT NULL,
+			cost REAL NOT NULL,
+			currency TEXT DEFAULT 'USD',
+			date TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS alerts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			threshold REAL NOT NULL,
+			subscription_id TEXT NOT NULL,
+			enabled INTEGER DEFAULT 1,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_cost_date ON cost_records(date)`,
+		`CREATE INDEX IF NOT EXISTS idx_cost_subscription ON cost_records(subscription_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_cost_service ON cost_records(service_name)`,
+	)},
+	{2, "cost record provider", func(tx *sql.Tx) error {
+		if err := addColumnIfMissing(tx, "cost_records", "provider", "TEXT DEFAULT 'azure'"); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_cost_provider ON cost_records(provider)`)
+		return err
+	}},
+	{3, "alert warning threshold", func(tx *sql.Tx) error {
+		return addColumnIfMissing(tx, "alerts", "warning_threshold", "REAL DEFAULT 0.8")
+	}},
+	{4, "alert service and resource group scope", func(tx *sql.Tx) error {
+		if err := addColumnIfMissing(tx, "alerts", "service_name", "TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		return addColumnIfMissing(tx, "alerts", "resource_group", "TEXT DEFAULT ''")
+	}},
+	{5, "deduplicate cost records", execAll(
+		// NULLs never conflict in a unique index, so normalize them first
+		`UPDATE cost_records SET resource_group = '' WHERE resource_group IS NULL`,
+		`UPDATE cost_records SET provider = 'azure' WHERE provider IS NULL`,
+		// Keep the most recently fetched row for each day
+		`DELETE FROM cost_records WHERE id NOT IN (
+			SELECT MAX(id) FROM cost_records
+			GROUP BY subscription_id, service_name, resource_group, date, provider
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_cost_unique
+			ON cost_records(subscription_id, service_name, resource_group, date, provider)`,
+	)},
+	{6, "alert notification state", func(tx *sql.Tx) error {
+		return addColumnIfMissing(tx, "alerts", "last_state", "TEXT DEFAULT ''")
+	}},
+	{7, "fetch state", execAll(
+		`CREATE TABLE IF NOT EXISTS fetch_state (
+			provider TEXT NOT NULL,
+			subscription_id TEXT NOT NULL,
+			last_date TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (provider, subscription_id)
+		)`,
+	)},
+	{8, "cost record resource and meter", func(tx *sql.Tx) error {
+		if err := addColumnIfMissing(tx, "cost_records", "resource_id", "TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		if err := addColumnIfMissing(tx, "cost_records", "meter", "TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		return execAll(
+			`DROP INDEX IF EXISTS idx_cost_unique`,
+			`CREATE UNIQUE INDEX idx_cost_unique
+				ON cost_records(subscription_id, service_name, resource_group, resource_id, meter, date, provider)`,
+		)(tx)
+	}},
+	{9, "unique alert names", func(tx *sql.Tx) error {
+		// Adding the same budget twice used to store it twice; keep the latest
+		result, err := tx.Exec(`DELETE FROM alerts WHERE id NOT IN (SELECT MAX(id) FROM alerts GROUP BY name)`)
+		if err != nil {
+			return err
+		}
+		if removed, err := result.RowsAffected(); err == nil && removed > 0 {
+			slog.Warn("removed duplicate alerts, keeping the latest of each name", "removed", removed)
+		}
+		_, err = tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_alert_name ON alerts(name)`)
+		return err
+	}},
+	{10, "fetch state per granularity", execAll(
+		// The old rows don't say which days they cover; dropping them only
+		// costs one full fetch
+		`DROP TABLE IF EXISTS fetch_state`,
+		`CREATE TABLE fetch_state (
+			provider TEXT NOT NULL,
+			subscription_id TEXT NOT NULL,
+			granularity TEXT NOT NULL,
+			first_date TEXT NOT NULL,
+			last_date TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (provider, subscription_id, granularity)
+		)`,
+	)},
+}
+
+func (db *DB) migrate() error {
+	if _, err := db.conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return err
+	}
+
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := db.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) appliedMigrations() (map[int]bool, error) {
+	rows, err := db.conn.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+func (db *DB) applyMigration(m migration) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := m.apply(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func execAll(statements ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, stmt := range statements {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// legacySchema is a database as written before schema versioning, holding a
+// duplicated cost row and a duplicated alert.
+const legacySchema = `
+CREATE TABLE config (key TEXT PRIMARY KEY, value TEXT, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
+CREATE TABLE cost_records (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	subscription_id TEXT NOT NULL,
+	resource_group TEXT,
+	service_name TEXT NOT NULL,
+	cost REAL NOT NULL,
+	currency TEXT DEFAULT 'USD',
+	date TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE alerts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	threshold REAL NOT NULL,
+	subscription_id TEXT NOT NULL,
+	enabled INTEGER DEFAULT 1,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO config (key, value) VALUES ('currency.target', 'EUR');
+INSERT INTO cost_records (subscription_id, resource_group, service_name, cost, date) VALUES
+	('sub-1', NULL, 'Storage', 2, '2026-09-01'),
+	('sub-1', NULL, 'Storage', 3, '2026-09-01'),
+	('sub-1', 'web-rg', 'Functions', 4, '2026-09-01');
+INSERT INTO alerts (name, threshold, subscription_id) VALUES
+	('budget-50', 50, ''),
+	('budget-50', 60, '');
+`
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "azguard.db")
+	legacy, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.Exec(legacySchema); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	db, err := New(path)
+	if err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	assertMigrationsApplied(t, db)
+
+	// The latest duplicate of each row survives, now with the new columns
+	records, err := db.GetCostRecords(CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d cost records, want 2 after deduplication", len(records))
+	}
+	total, err := db.GetTotalCost(CostFilter{Provider: "azure"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 7 {
+		t.Errorf("azure total = %.2f, want 7.00", total)
+	}
+	alert := savedAlert(t, db, "budget-50")
+	if alert.Threshold != 60 || alert.WarningThreshold != DefaultWarningThreshold {
+		t.Errorf("alert = %+v, want the later 60.00 budget with the default warning", alert)
+	}
+	if value, err := db.GetConfig("currency.target"); err != nil || value != "EUR" {
+		t.Errorf("config currency.target = %q (err %v), want EUR", value, err)
+	}
+	db.Close()
+
+	// Reopening applies nothing again
+	db, err = New(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer db.Close()
+	assertMigrationsApplied(t, db)
+	if records, err := db.GetCostRecords(CostFilter{}); err != nil || len(records) != 2 {
+		t.Errorf("got %d records (err %v) after reopening, want 2", len(records), err)
+	}
+}
+
+func assertMigrationsApplied(t *testing.T, db *DB) {
+	t.Helper()
+	var count, latest int
+	if err := db.conn.QueryRow("SELECT COUNT(*), MAX(version) FROM schema_migrations").Scan(&count, &latest); err != nil {
+		t.Fatal(err)
+	}
+	last := migrations[len(migrations)-1].version
+	if count != len(migrations) || latest != last {
+		t.Errorf("schema_migrations has %d rows up to version %d, want %d up to %d", count, latest, len(migrations), last)
+	}
+}
@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 
 	_ "modernc.org/sqlite"
 )
 
 type DB struct {
 	conn *sql.DB
+
+	// costVersion is bumped on every write to cost_records
+	costVersion atomic.Uint64
 }
 
 func New(path string) (*DB, error) {
@@ -19,11 +23,19 @@ func New(path string) (*DB, error) {
 		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	conn, err := sql.Open("sqlite", path+"?_foreign_keys=ON")
+	// modernc.org/sqlite applies _pragma parameters to every new connection,
+	// so the busy timeout survives the pool recycling connections. WAL lets
+	// readers proceed while another process (CLI or API server) is writing.
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	conn, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	// SQLite allows a single writer; funnelling this process through one
+	// connection avoids SQLITE_BUSY between its own goroutines.
+	conn.SetMaxOpenConns(1)
+
 	if err := conn.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
@@ -36,48 +48,26 @@ func New(path string) (*DB, error) {
 	return db, nil
 }
 
-func (db *DB) migrate() error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS config (
-			key TEXT PRIMARY KEY,
-			value TEXT,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS cost_records (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			subscription_id TEXT NOT NULL,
-			resource_group TEXT,
-			service_name TEXT NOT NULL,
-			cost REAL NOT NULL,
-			currency TEXT DEFAULT 'USD',
-			date TEXT NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS alerts (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			threshold REAL NOT NULL,
-			subscription_id TEXT NOT NULL,
-			enabled INTEGER DEFAULT 1,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_cost_date ON cost_records(date)`,
-		`CREATE INDEX IF NOT EXISTS idx_cost_subscription ON cost_records(subscription_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_cost_service ON cost_records(service_name)`,
-	}
-
-	for _, m := range migrations {
-		if _, err := db.conn.Exec(m); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
+// inTx runs fn in a transaction, committing it if fn succeeds. Writes go
+// through it so a write shared between goroutines is applied whole or not at
+// all.
+func (db *DB) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (db *DB) GetConfig(key string) (string, error) {
 	var value string
 	err := db.conn.QueryRow("SELECT value FROM config WHERE key = ?", key).Scan(&value)
@@ -88,117 +78,343 @@ func (db *DB) GetConfig(key string) (string, error) {
 }
 
 func (db *DB) SetConfig(key, value string) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO config (key, value, updated_at)
+			VALUES (?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP
+		`, key, value)
+		return err
+	})
+}
+
+// GetSubscriptionIDs returns the distinct subscriptions with stored costs.
+func (db *DB) GetSubscriptionIDs() ([]string, error) {
+	rows, err := db.conn.Query("SELECT DISTINCT subscription_id FROM cost_records ORDER BY subscription_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteConfig removes a stored config override. Removing a key that isn't
+// set is not an error.
+func (db *DB) DeleteConfig(key string) error {
+	_, err := db.conn.Exec("DELETE FROM config WHERE key = ?", key)
+	return err
+}
+
+// GetAllConfig returns every stored config override keyed by name.
+func (db *DB) GetAllConfig() (map[string]string, error) {
+	rows, err := db.conn.Query("SELECT key, value FROM config")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+	return result, rows.Err()
+}
+
+// FetchState is the contiguous range of dates, inclusive, that has been
+// fetched for a provider's subscription at one granularity.
+type FetchState struct {
+	FirstDate string
+	LastDate  string
+}
+
+// GetFetchState returns what has been fetched for a provider's subscription
+// at the granularity, or a zero FetchState if nothing has.
+func (db *DB) GetFetchState(provider, subscriptionID, granularity string) (FetchState, error) {
+	var state FetchState
+	err := db.conn.QueryRow("SELECT first_date, last_date FROM fetch_state WHERE provider = ? AND subscription_id = ? AND granularity = ?",
+		providerOrDefault(provider), subscriptionID, granularity).Scan(&state.FirstDate, &state.LastDate)
+	if err == sql.ErrNoRows {
+		return FetchState{}, nil
+	}
+	return state, err
+}
+
+// SetFetchState records what has been fetched for a provider's subscription
+// at the granularity.
+func (db *DB) SetFetchState(provider, subscriptionID, granularity string, state FetchState) error {
 	_, err := db.conn.Exec(`
-		INSERT INTO config (key, value, updated_at)
-		VALUES (?, ?, CURRENT_TIMESTAMP)
-		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP
-	`, key, value)
+		INSERT INTO fetch_state (provider, subscription_id, granularity, first_date, last_date, updated_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(provider, subscription_id, granularity) DO UPDATE SET
+			first_date = excluded.first_date, last_date = excluded.last_date, updated_at = CURRENT_TIMESTAMP
+	`, providerOrDefault(provider), subscriptionID, granularity, state.FirstDate, state.LastDate)
+	return err
+}
+
+// DeleteFetchState forgets what has been fetched for a provider's
+// subscription at the granularity.
+func (db *DB) DeleteFetchState(provider, subscriptionID, granularity string) error {
+	_, err := db.conn.Exec("DELETE FROM fetch_state WHERE provider = ? AND subscription_id = ? AND granularity = ?",
+		providerOrDefault(provider), subscriptionID, granularity)
 	return err
 }
 
 type CostRecord struct {
-	ID              int64
-	SubscriptionID  string
-	ResourceGroup   string
-	ServiceName     string
-	Cost            float64
-	Currency        string
-	Date            string
+	ID             int64
+	SubscriptionID string
+	ResourceGroup  string
+	ServiceName    string
+	Cost           float64
+	Currency       string
+	Date           string
+	Provider       string
+	// ResourceID and Meter are only set for resource-level records
+	ResourceID string
+	Meter      string
+}
+
+// insertCostRecord upserts so that re-fetching a day replaces its stored cost
+// rather than adding to it.
+const insertCostRecord = `
+	INSERT INTO cost_records (subscription_id, resource_group, service_name, cost, currency, date, provider, resource_id, meter)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(subscription_id, service_name, resource_group, resource_id, meter, date, provider)
+	DO UPDATE SET cost = excluded.cost, currency = excluded.currency
+`
+
+func (c CostRecord) insertArgs() []interface{} {
+	return []interface{}{c.SubscriptionID, c.ResourceGroup, c.ServiceName, c.Cost, c.Currency, c.Date, providerOrDefault(c.Provider), c.ResourceID, c.Meter}
 }
 
 func (db *DB) SaveCostRecord(record CostRecord) error {
-	_, err := db.conn.Exec(`
-		INSERT INTO cost_records (subscription_id, resource_group, service_name, cost, currency, date)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, record.SubscriptionID, record.ResourceGroup, record.ServiceName, record.Cost, record.Currency, record.Date)
-	return err
+	return db.SaveCostRecords([]CostRecord{record})
 }
 
+// SaveCostRecords upserts the records in one transaction.
 func (db *DB) SaveCostRecords(records []CostRecord) error {
-	tx, err := db.conn.Begin()
+	return db.ReplaceCostRecords(nil, records)
+}
+
+// CostRange is the dates, inclusive, that a fetch covered for one provider
+// subscription.
+type CostRange struct {
+	Provider       string
+	SubscriptionID string
+	StartDate      string
+	EndDate        string
+}
+
+// ReplaceCostRecords deletes the records stored in each range, at any level
+// of detail, and saves records in their place, in one transaction. Fetches use
+// it so costs that have gone from the provider's data, or were stored at
+// another granularity, are not summed with the new ones.
+func (db *DB) ReplaceCostRecords(ranges []CostRange, records []CostRecord) error {
+	err := db.inTx(func(tx *sql.Tx) error {
+		for _, r := range ranges {
+			if _, err := tx.Exec("DELETE FROM cost_records WHERE provider = ? AND subscription_id = ? AND date >= ? AND date <= ?",
+				providerOrDefault(r.Provider), r.SubscriptionID, r.StartDate, r.EndDate); err != nil {
+				return err
+			}
+		}
+
+		stmt, err := tx.Prepare(insertCostRecord)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, r := range records {
+			if _, err := stmt.Exec(r.insertArgs()...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
+	db.costVersion.Add(1)
+	return nil
+}
+
+// CostVersion changes whenever this DB writes cost records, so results
+// derived from them can be cached until the next write. Writes made by other
+// processes are not seen.
+func (db *DB) CostVersion() uint64 {
+	return db.costVersion.Load()
+}
+
+// providerOrDefault keeps records written without a provider attributed to
+// Azure, which was the only source before multi-cloud support.
+func providerOrDefault(provider string) string {
+	if provider == "" {
+		return "azure"
+	}
+	return provider
+}
+
+// PruneCostRecords deletes cost records dated before the given YYYY-MM-DD
+// date and reclaims the freed space. It returns the number of rows removed.
+func (db *DB) PruneCostRecords(before string) (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
 	defer func() { _ = tx.Rollback() }()
 
-	stmt, err := tx.Prepare(`
-		INSERT INTO cost_records (subscription_id, resource_group, service_name, cost, currency, date)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`)
+	result, err := tx.Exec("DELETE FROM cost_records WHERE date < ?", before)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
 	}
-	defer stmt.Close()
 
-	for _, r := range records {
-		if _, err := stmt.Exec(r.SubscriptionID, r.ResourceGroup, r.ServiceName, r.Cost, r.Currency, r.Date); err != nil {
-			return err
-		}
+	if err := tx.Commit(); err != nil {
+		return 0, err
 	}
+	db.costVersion.Add(1)
 
-	return tx.Commit()
+	// VACUUM cannot run inside a transaction
+	if removed > 0 {
+		if _, err := db.conn.Exec("VACUUM"); err != nil {
+			return removed, fmt.Errorf("records pruned but vacuum failed: %w", err)
+		}
+	}
+	return removed, nil
 }
 
 type CostFilter struct {
-	StartDate   string
-	EndDate     string
-	ServiceName string
-	GroupBy     string
+	StartDate      string
+	EndDate        string
+	ServiceName    string
+	Provider       string
+	SubscriptionID string
+	ResourceGroup  string
+	GroupBy        string
+
+	// Limit and Offset page the records GetCostRecords and EachCostRecord
+	// return; aggregate queries ignore them. A zero Limit means no limit.
+	Limit  int
+	Offset int
 }
 
-func (db *DB) GetCostRecords(filter CostFilter) ([]CostRecord, error) {
-	query := "SELECT id, subscription_id, resource_group, service_name, cost, currency, date FROM cost_records WHERE 1=1"
+// conditions returns the WHERE clause fragments and arguments shared by all
+// cost_records queries.
+func (f CostFilter) conditions() (string, []interface{}) {
+	var clause string
 	args := []interface{}{}
 
-	if filter.StartDate != "" {
-		query += " AND date >= ?"
-		args = append(args, filter.StartDate)
+	if f.StartDate != "" {
+		clause += " AND date >= ?"
+		args = append(args, f.StartDate)
+	}
+	if f.EndDate != "" {
+		clause += " AND date <= ?"
+		args = append(args, f.EndDate)
+	}
+	if f.ServiceName != "" {
+		clause += " AND service_name = ?"
+		args = append(args, f.ServiceName)
 	}
-	if filter.EndDate != "" {
-		query += " AND date <= ?"
-		args = append(args, filter.EndDate)
+	if f.Provider != "" {
+		clause += " AND provider = ?"
+		args = append(args, f.Provider)
 	}
-	if filter.ServiceName != "" {
-		query += " AND service_name = ?"
-		args = append(args, filter.ServiceName)
+	if f.SubscriptionID != "" {
+		clause += " AND subscription_id = ?"
+		args = append(args, f.SubscriptionID)
 	}
+	if f.ResourceGroup != "" {
+		clause += " AND resource_group = ?"
+		args = append(args, f.ResourceGroup)
+	}
+	return clause, args
+}
 
-	query += " ORDER BY date DESC"
+func (db *DB) GetCostRecords(filter CostFilter) ([]CostRecord, error) {
+	var records []CostRecord
+	err := db.EachCostRecord(filter, func(r CostRecord) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// EachCostRecord streams matching records, newest first, to fn without
+// loading them all into memory. Iteration stops at the first error fn
+// returns. fn must not call back into db: the single pooled connection is
+// busy until iteration finishes.
+func (db *DB) EachCostRecord(filter CostFilter, fn func(CostRecord) error) error {
+	query := "SELECT id, subscription_id, resource_group, service_name, cost, currency, date, provider, resource_id, meter FROM cost_records WHERE 1=1"
+	clause, args := filter.conditions()
+	query += clause
+
+	// id breaks ties so that pages are stable
+	query += " ORDER BY date DESC, id DESC"
+	if filter.Limit > 0 || filter.Offset > 0 {
+		limit := filter.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, filter.Offset)
+	}
 
 	rows, err := db.conn.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var records []CostRecord
 	for rows.Next() {
 		var r CostRecord
-		if err := rows.Scan(&r.ID, &r.SubscriptionID, &r.ResourceGroup, &r.ServiceName, &r.Cost, &r.Currency, &r.Date); err != nil {
-			return nil, err
+		if err := rows.Scan(&r.ID, &r.SubscriptionID, &r.ResourceGroup, &r.ServiceName, &r.Cost, &r.Currency, &r.Date, &r.Provider, &r.ResourceID, &r.Meter); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
 		}
-		records = append(records, r)
 	}
-	return records, nil
+	return rows.Err()
 }
 
-func (db *DB) GetAggregatedCosts(filter CostFilter) (map[string]float64, error) {
-	groupBy := "service_name"
-	if filter.GroupBy == "ResourceGroup" {
-		groupBy = "resource_group"
+// groupColumn maps a CostFilter.GroupBy value to the column it groups on.
+// Anything unrecognised groups by service.
+func groupColumn(groupBy string) string {
+	switch groupBy {
+	case "ResourceGroup":
+		return "resource_group"
+	case "Provider":
+		return "provider"
+	default:
+		return "service_name"
 	}
+}
 
-	query := fmt.Sprintf("SELECT %s, SUM(cost) as total FROM cost_records WHERE 1=1", groupBy)
-	args := []interface{}{}
+func (db *DB) GetAggregatedCosts(filter CostFilter) (map[string]float64, error) {
+	groupBy := groupColumn(filter.GroupBy)
 
-	if filter.StartDate != "" {
-		query += " AND date >= ?"
-		args = append(args, filter.StartDate)
-	}
-	if filter.EndDate != "" {
-		query += " AND date <= ?"
-		args = append(args, filter.EndDate)
-	}
+	query := fmt.Sprintf("SELECT %s, SUM(cost) as total FROM cost_records WHERE 1=1", groupBy)
+	clause, args := filter.conditions()
+	query += clause
 
 	query += " GROUP BY " + groupBy
 
@@ -220,23 +436,100 @@ func (db *DB) GetAggregatedCosts(filter CostFilter) (map[string]float64, error)
 	return result, nil
 }
 
+// GetAggregatedCostsByCurrency is like GetAggregatedCosts but keeps totals in
+// different currencies apart, keyed by currency code and then group name.
+func (db *DB) GetAggregatedCostsByCurrency(filter CostFilter) (map[string]map[string]float64, error) {
+	groupBy := groupColumn(filter.GroupBy)
+
+	query := fmt.Sprintf("SELECT %s, COALESCE(NULLIF(currency, ''), 'USD') as cur, SUM(cost) as total FROM cost_records WHERE 1=1", groupBy)
+	clause, args := filter.conditions()
+	query += clause
+
+	query += " GROUP BY " + groupBy + ", cur"
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]map[string]float64)
+	for rows.Next() {
+		var name, currency string
+		var total float64
+		if err := rows.Scan(&name, &currency, &total); err != nil {
+			return nil, err
+		}
+		if result[currency] == nil {
+			result[currency] = make(map[string]float64)
+		}
+		result[currency][name] = total
+	}
+	return result, nil
+}
+
 type MonthlyCost struct {
 	Month     string
 	TotalCost float64
 	Currency  string
 }
 
-func (db *DB) GetMonthlyCosts(months int) ([]MonthlyCost, error) {
+type DailyCost struct {
+	Date      string
+	TotalCost float64
+	Currency  string
+}
+
+// WeeklyCost is the total for the Monday-to-Sunday week starting on Week,
+// a YYYY-MM-DD date.
+type WeeklyCost struct {
+	Week      string
+	TotalCost float64
+	Currency  string
+}
+
+// GetDailyCosts totals the filtered records per day and currency, oldest
+// first.
+func (db *DB) GetDailyCosts(filter CostFilter) ([]DailyCost, error) {
+	clause, args := filter.conditions()
 	query := `
-		SELECT strftime('%Y-%m', date) as month, SUM(cost) as total, currency 
+		SELECT date, SUM(cost) as total, COALESCE(NULLIF(currency, ''), 'USD') as cur
+		FROM cost_records
+		WHERE 1=1` + clause + `
+		GROUP BY date, cur
+		ORDER BY date ASC
+	`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []DailyCost
+	for rows.Next() {
+		var d DailyCost
+		if err := rows.Scan(&d.Date, &d.TotalCost, &d.Currency); err != nil {
+			return nil, err
+		}
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
+func (db *DB) GetMonthlyCosts(months int, filter CostFilter) ([]MonthlyCost, error) {
+	clause, filterArgs := filter.conditions()
+	query := `
+		SELECT strftime('%Y-%m', date) as month, SUM(cost) as total, COALESCE(NULLIF(currency, ''), 'USD') as cur
 		FROM cost_records 
-		WHERE date >= date('now', ?)
-		GROUP BY strftime('%Y-%m', date), currency
+		WHERE date >= date('now', ?)` + clause + `
+		GROUP BY strftime('%Y-%m', date), cur
 		ORDER BY month DESC
 	`
 
 	monthsAgo := fmt.Sprintf("-%d months", months)
-	rows, err := db.conn.Query(query, monthsAgo)
+	args := append([]interface{}{monthsAgo}, filterArgs...)
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -253,34 +546,84 @@ func (db *DB) GetMonthlyCosts(months int) ([]MonthlyCost, error) {
 	return results, nil
 }
 
-func (db *DB) GetTotalCost(filter CostFilter) (float64, error) {
-	query := "SELECT COALESCE(SUM(cost), 0) FROM cost_records WHERE 1=1"
-	args := []interface{}{}
+// GetWeeklyCosts totals the filtered records per week for the current week
+// and the weeks-1 before it, newest first. Weeks are bucketed by their
+// Monday rather than strftime('%W') so a week spanning New Year stays whole.
+func (db *DB) GetWeeklyCosts(weeks int, filter CostFilter) ([]WeeklyCost, error) {
+	clause, filterArgs := filter.conditions()
+	query := `
+		SELECT date(date, '-6 days', 'weekday 1') as week, SUM(cost) as total, COALESCE(NULLIF(currency, ''), 'USD') as cur
+		FROM cost_records
+		WHERE date >= date('now', '-6 days', 'weekday 1', ?)` + clause + `
+		GROUP BY week, cur
+		ORDER BY week DESC
+	`
 
-	if filter.StartDate != "" {
-		query += " AND date >= ?"
-		args = append(args, filter.StartDate)
+	weeksAgo := fmt.Sprintf("-%d days", (weeks-1)*7)
+	args := append([]interface{}{weeksAgo}, filterArgs...)
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
 	}
-	if filter.EndDate != "" {
-		query += " AND date <= ?"
-		args = append(args, filter.EndDate)
+	defer rows.Close()
+
+	var results []WeeklyCost
+	for rows.Next() {
+		var w WeeklyCost
+		if err := rows.Scan(&w.Week, &w.TotalCost, &w.Currency); err != nil {
+			return nil, err
+		}
+		results = append(results, w)
 	}
+	return results, rows.Err()
+}
+
+// LatestCostDate returns the most recent date with a filtered cost record,
+// or "" if there are none.
+func (db *DB) LatestCostDate(filter CostFilter) (string, error) {
+	query := "SELECT COALESCE(MAX(date), '') FROM cost_records WHERE 1=1"
+	clause, args := filter.conditions()
+	query += clause
+
+	var latest string
+	err := db.conn.QueryRow(query, args...).Scan(&latest)
+	return latest, err
+}
+
+func (db *DB) GetTotalCost(filter CostFilter) (float64, error) {
+	query := "SELECT COALESCE(SUM(cost), 0) FROM cost_records WHERE 1=1"
+	clause, args := filter.conditions()
+	query += clause
 
 	var total float64
 	err := db.conn.QueryRow(query, args...).Scan(&total)
 	return total, err
 }
 
+// DefaultWarningThreshold is the fraction of an alert's threshold at which
+// it starts reporting a warning.
+const DefaultWarningThreshold = 0.8
+
 type Alert struct {
-	ID             int64
-	Name           string
-	Threshold      float64
-	SubscriptionID string
-	Enabled        bool
+	ID               int64
+	Name             string
+	Threshold        float64
+	SubscriptionID   string
+	Enabled          bool
+	WarningThreshold float64
+	// ServiceName or ResourceGroup, when set, scope the alert to that slice
+	// of spend instead of the subscription total
+	ServiceName   string
+	ResourceGroup string
+	// LastState is the state recorded the last time the alert was checked,
+	// so notifications fire once per threshold crossing
+	LastState string
 }
 
+const alertColumns = "id, name, threshold, subscription_id, enabled, COALESCE(warning_threshold, 0.8), COALESCE(service_name, ''), COALESCE(resource_group, ''), COALESCE(last_state, '')"
+
 func (db *DB) GetAlerts() ([]Alert, error) {
-	rows, err := db.conn.Query("SELECT id, name, threshold, subscription_id, enabled FROM alerts ORDER BY name")
+	rows, err := db.conn.Query("SELECT " + alertColumns + " FROM alerts ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
@@ -289,7 +632,7 @@ func (db *DB) GetAlerts() ([]Alert, error) {
 	var alerts []Alert
 	for rows.Next() {
 		var a Alert
-		if err := rows.Scan(&a.ID, &a.Name, &a.Threshold, &a.SubscriptionID, &a.Enabled); err != nil {
+		if err := rows.Scan(&a.ID, &a.Name, &a.Threshold, &a.SubscriptionID, &a.Enabled, &a.WarningThreshold, &a.ServiceName, &a.ResourceGroup, &a.LastState); err != nil {
 			return nil, err
 		}
 		alerts = append(alerts, a)
@@ -297,23 +640,85 @@ func (db *DB) GetAlerts() ([]Alert, error) {
 	return alerts, nil
 }
 
+// SaveAlert adds an alert, or replaces the settings of the alert with the
+// same name. Its last notified state is kept so a re-saved alert that is
+// already triggered doesn't notify again.
 func (db *DB) SaveAlert(alert Alert) error {
-	_, err := db.conn.Exec(`
-		INSERT INTO alerts (name, threshold, subscription_id, enabled)
-		VALUES (?, ?, ?, ?)
-	`, alert.Name, alert.Threshold, alert.SubscriptionID, alert.Enabled)
-	return err
+	if alert.WarningThreshold == 0 {
+		alert.WarningThreshold = DefaultWarningThreshold
+	}
+	return db.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO alerts (name, threshold, subscription_id, enabled, warning_threshold, service_name, resource_group)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET
+				-- A state reached against the old budget says nothing about the new one
+				last_state = CASE WHEN alerts.threshold != excluded.threshold
+					OR alerts.warning_threshold != excluded.warning_threshold
+					OR alerts.subscription_id != excluded.subscription_id
+					OR alerts.service_name != excluded.service_name
+					OR alerts.resource_group != excluded.resource_group
+					THEN '' ELSE alerts.last_state END,
+				threshold = excluded.threshold,
+				subscription_id = excluded.subscription_id,
+				enabled = excluded.enabled,
+				warning_threshold = excluded.warning_threshold,
+				service_name = excluded.service_name,
+				resource_group = excluded.resource_group
+		`, alert.Name, alert.Threshold, alert.SubscriptionID, alert.Enabled, alert.WarningThreshold, alert.ServiceName, alert.ResourceGroup)
+		return err
+	})
 }
 
 func (db *DB) DeleteAlert(name string) error {
-	_, err := db.conn.Exec("DELETE FROM alerts WHERE name = ?", name)
-	return err
+	return db.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM alerts WHERE name = ?", name)
+		return err
+	})
+}
+
+func (db *DB) SetAlertEnabled(name string, enabled bool) error {
+	result, err := db.conn.Exec("UPDATE alerts SET enabled = ? WHERE name = ?", enabled, name)
+	if err != nil {
+		return err
+	}
+	return requireAlertUpdated(result, name)
+}
+
+// UpdateAlertThreshold changes an alert's budget and clears its last state,
+// so crossing the new threshold notifies again.
+func (db *DB) UpdateAlertThreshold(name string, threshold float64) error {
+	result, err := db.conn.Exec("UPDATE alerts SET threshold = ?, last_state = '' WHERE name = ?", threshold, name)
+	if err != nil {
+		return err
+	}
+	return requireAlertUpdated(result, name)
+}
+
+// SetAlertLastState records the state an alert was last evaluated in.
+func (db *DB) SetAlertLastState(name, state string) error {
+	result, err := db.conn.Exec("UPDATE alerts SET last_state = ? WHERE name = ?", state, name)
+	if err != nil {
+		return err
+	}
+	return requireAlertUpdated(result, name)
+}
+
+func requireAlertUpdated(result sql.Result, name string) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("alert '%s' not found", name)
+	}
+	return nil
 }
 
 func (db *DB) GetAlertByName(name string) (*Alert, error) {
 	var a Alert
-	err := db.conn.QueryRow("SELECT id, name, threshold, subscription_id, enabled FROM alerts WHERE name = ?", name).
-		Scan(&a.ID, &a.Name, &a.Threshold, &a.SubscriptionID, &a.Enabled)
+	err := db.conn.QueryRow("SELECT "+alertColumns+" FROM alerts WHERE name = ?", name).
+		Scan(&a.ID, &a.Name, &a.Threshold, &a.SubscriptionID, &a.Enabled, &a.WarningThreshold, &a.ServiceName, &a.ResourceGroup, &a.LastState)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
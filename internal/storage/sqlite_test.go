@@ -0,0 +1,456 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := New(filepath.Join(t.TempDir(), "azguard.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func savedAlert(t *testing.T, db *DB, name string) *Alert {
+	t.Helper()
+	alert, err := db.GetAlertByName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert == nil {
+		t.Fatalf("alert %s not found", name)
+	}
+	return alert
+}
+
+func TestSaveAlertKeepsStateForUnchangedBudget(t *testing.T) {
+	db := newTestDB(t)
+	alert := Alert{Name: "budget-50", Threshold: 50, Enabled: true}
+	if err := db.SaveAlert(alert); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetAlertLastState("budget-50", "triggered"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving it again, e.g. to re-enable it, is not a new crossing
+	if err := db.SaveAlert(alert); err != nil {
+		t.Fatal(err)
+	}
+	if got := savedAlert(t, db, "budget-50").LastState; got != "triggered" {
+		t.Errorf("last state = %q, want triggered", got)
+	}
+
+	alerts, err := db.GetAlerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 {
+		t.Errorf("got %d alerts, want 1", len(alerts))
+	}
+}
+
+func TestSaveAlertResetsStateWhenBudgetChanges(t *testing.T) {
+	for name, change := range map[string]func(*Alert){
+		"threshold":      func(a *Alert) { a.Threshold = 80 },
+		"warning":        func(a *Alert) { a.WarningThreshold = 0.5 },
+		"service":        func(a *Alert) { a.ServiceName = "Storage" },
+		"resource group": func(a *Alert) { a.ResourceGroup = "web-rg" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			db := newTestDB(t)
+			alert := Alert{Name: "budget", Threshold: 50, Enabled: true}
+			if err := db.SaveAlert(alert); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.SetAlertLastState("budget", "triggered"); err != nil {
+				t.Fatal(err)
+			}
+
+			change(&alert)
+			if err := db.SaveAlert(alert); err != nil {
+				t.Fatal(err)
+			}
+			if got := savedAlert(t, db, "budget").LastState; got != "" {
+				t.Errorf("last state = %q, want it reset", got)
+			}
+		})
+	}
+}
+
+func TestUpdateAlertThresholdResetsState(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.SaveAlert(Alert{Name: "budget-50", Threshold: 50, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetAlertLastState("budget-50", "triggered"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.UpdateAlertThreshold("budget-50", 80); err != nil {
+		t.Fatal(err)
+	}
+	alert := savedAlert(t, db, "budget-50")
+	if alert.Threshold != 80 || alert.LastState != "" {
+		t.Errorf("got threshold %.2f and state %q, want 80.00 and no state", alert.Threshold, alert.LastState)
+	}
+
+	if err := db.UpdateAlertThreshold("missing", 80); err == nil {
+		t.Error("expected an error updating a missing alert")
+	}
+}
+
+func TestSaveCostRecordsTwiceDoesNotDoubleCount(t *testing.T) {
+	db := newTestDB(t)
+	day := []CostRecord{
+		{SubscriptionID: "sub-1", ResourceGroup: "web-rg", ServiceName: "Storage", Cost: 2.5, Currency: "USD", Date: "2026-10-01"},
+		{SubscriptionID: "sub-1", ResourceGroup: "web-rg", ServiceName: "Virtual Machines", Cost: 7.5, Currency: "USD", Date: "2026-10-01"},
+	}
+
+	if err := db.SaveCostRecords(day); err != nil {
+		t.Fatal(err)
+	}
+	first, err := db.GetTotalCost(CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveCostRecords(day); err != nil {
+		t.Fatal(err)
+	}
+	second, err := db.GetTotalCost(CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 10 || second != first {
+		t.Errorf("totals after storing the day once and twice = %.2f and %.2f, want 10.00 both times", first, second)
+	}
+}
+
+func TestSaveCostRecordsUpdatesRevisedCost(t *testing.T) {
+	db := newTestDB(t)
+	record := CostRecord{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 2.5, Currency: "USD", Date: "2026-10-01"}
+	if err := db.SaveCostRecord(record); err != nil {
+		t.Fatal(err)
+	}
+
+	// Azure revised the day's cost after it was first fetched
+	record.Cost = 3
+	if err := db.SaveCostRecord(record); err != nil {
+		t.Fatal(err)
+	}
+	total, err := db.GetTotalCost(CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 {
+		t.Errorf("total = %.2f, want the revised 3.00", total)
+	}
+}
+
+func TestGetWeeklyCostsGroupsAcrossMonthBoundary(t *testing.T) {
+	// The most recent first of the month that falls mid-week
+	now := time.Now()
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if first.Weekday() == time.Monday {
+		first = first.AddDate(0, -1, 0)
+	}
+	monday := first.AddDate(0, 0, -(int(first.Weekday())+6)%7)
+	day := func(d time.Time) string { return d.Format("2006-01-02") }
+
+	db := newTestDB(t)
+	if err := db.SaveCostRecords([]CostRecord{
+		{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 1, Currency: "USD", Date: day(monday.AddDate(0, 0, -1))},
+		{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 2, Currency: "USD", Date: day(first.AddDate(0, 0, -1))},
+		{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 3, Currency: "USD", Date: day(first)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	weeks, err := db.GetWeeklyCosts(12, CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WeeklyCost{
+		{Week: day(monday), TotalCost: 5, Currency: "USD"},
+		{Week: day(monday.AddDate(0, 0, -7)), TotalCost: 1, Currency: "USD"},
+	}
+	if len(weeks) != len(want) {
+		t.Fatalf("weeks = %+v, want %+v", weeks, want)
+	}
+	for i := range want {
+		if weeks[i] != want[i] {
+			t.Errorf("week %d = %+v, want %+v", i, weeks[i], want[i])
+		}
+	}
+}
+
+func TestResourceRecordsKeepResourceAndMeter(t *testing.T) {
+	db := newTestDB(t)
+	vm := "/subscriptions/sub-1/resourceGroups/web-rg/providers/Microsoft.Compute/virtualMachines/web-1"
+	records := []CostRecord{
+		{SubscriptionID: "sub-1", ResourceGroup: "web-rg", ServiceName: "Virtual Machines", Cost: 4, Currency: "USD", Date: "2026-10-01", Provider: "azure", ResourceID: vm, Meter: "D2s v3"},
+		// Same resource and day, different meter: a separate row
+		{SubscriptionID: "sub-1", ResourceGroup: "web-rg", ServiceName: "Storage", Cost: 0.5, Currency: "USD", Date: "2026-10-01", Provider: "azure", ResourceID: vm, Meter: "P10 Disks"},
+	}
+	if err := db.SaveCostRecords(records); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := db.GetCostRecords(CostFilter{ResourceGroup: "web-rg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != len(records) {
+		t.Fatalf("got %d records, want %d", len(stored), len(records))
+	}
+	byMeter := map[string]CostRecord{}
+	for _, r := range stored {
+		r.ID = 0
+		byMeter[r.Meter] = r
+	}
+	for _, want := range records {
+		if got := byMeter[want.Meter]; got != want {
+			t.Errorf("stored %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestConcurrentAlertWrites(t *testing.T) {
+	db := newTestDB(t)
+	const writers = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*6)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("budget-%d", i)
+			errs <- db.SaveAlert(Alert{Name: name, Threshold: float64(i + 1), Enabled: true})
+			_, err := db.GetAlerts()
+			errs <- err
+			errs <- db.SaveCostRecords([]CostRecord{{SubscriptionID: "sub-1", ServiceName: name, Cost: 1, Currency: "USD", Date: "2026-10-01"}})
+			errs <- db.SetConfig("currency.target", "USD")
+			if i%2 == 1 {
+				errs <- db.DeleteAlert(name)
+			}
+			_, err = db.GetTotalCost(CostFilter{})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	alerts, err := db.GetAlerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, a := range alerts {
+		got[a.Name] = true
+	}
+	for i := 0; i < writers; i++ {
+		name := fmt.Sprintf("budget-%d", i)
+		if got[name] != (i%2 == 0) {
+			t.Errorf("alert %s present = %v, want %v", name, got[name], i%2 == 0)
+		}
+	}
+	if len(alerts) != writers/2 {
+		t.Errorf("got %d alerts, want %d", len(alerts), writers/2)
+	}
+	total, err := db.GetTotalCost(CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != writers {
+		t.Errorf("total cost = %.2f, want %d.00", total, writers)
+	}
+}
+
+func TestProviderFilterIsolatesMixedProviderCosts(t *testing.T) {
+	db := newTestDB(t)
+	today := time.Now().Format("2006-01-02")
+	if err := db.SaveCostRecords([]CostRecord{
+		{Provider: "azure", SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 10, Currency: "USD", Date: today},
+		{Provider: "azure", SubscriptionID: "sub-1", ServiceName: "Virtual Machines", Cost: 5, Currency: "USD", Date: today},
+		{Provider: "aws", SubscriptionID: "123456789012", ServiceName: "Amazon S3", Cost: 7, Currency: "USD", Date: today},
+		// Stored before multi-cloud support, so attributed to Azure
+		{SubscriptionID: "sub-1", ServiceName: "Functions", Cost: 1, Currency: "USD", Date: today},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for provider, want := range map[string]struct {
+		total    float64
+		services map[string]float64
+	}{
+		"azure": {16, map[string]float64{"Storage": 10, "Virtual Machines": 5, "Functions": 1}},
+		"aws":   {7, map[string]float64{"Amazon S3": 7}},
+		"gcp":   {0, map[string]float64{}},
+	} {
+		filter := CostFilter{Provider: provider}
+
+		total, err := db.GetTotalCost(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != want.total {
+			t.Errorf("%s total = %.2f, want %.2f", provider, total, want.total)
+		}
+
+		filter.GroupBy = "ServiceName"
+		services, err := db.GetAggregatedCosts(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(services) != len(want.services) {
+			t.Errorf("%s services = %v, want %v", provider, services, want.services)
+		}
+		for name, cost := range want.services {
+			if services[name] != cost {
+				t.Errorf("%s %s = %.2f, want %.2f", provider, name, services[name], cost)
+			}
+		}
+
+		months, err := db.GetMonthlyCosts(1, CostFilter{Provider: provider})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var monthly float64
+		for _, m := range months {
+			monthly += m.TotalCost
+		}
+		if monthly != want.total {
+			t.Errorf("%s monthly total = %.2f, want %.2f", provider, monthly, want.total)
+		}
+	}
+
+	byProvider, err := db.GetAggregatedCosts(CostFilter{GroupBy: "Provider"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byProvider["azure"] != 16 || byProvider["aws"] != 7 || len(byProvider) != 2 {
+		t.Errorf("costs by provider = %v, want azure 16 and aws 7", byProvider)
+	}
+}
+
+func TestGetMonthlyCostsDefaultsMissingCurrency(t *testing.T) {
+	db := newTestDB(t)
+	today := time.Now().Format("2006-01-02")
+	// Rows written before the currency column was filled in
+	if _, err := db.conn.Exec(`INSERT INTO cost_records (subscription_id, resource_group, service_name, cost, currency, date)
+		VALUES ('sub-1', '', 'Storage', 2, NULL, ?), ('sub-1', '', 'Functions', 1, '', ?)`, today, today); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveCostRecord(CostRecord{SubscriptionID: "sub-1", ServiceName: "Blob", Cost: 3, Currency: "USD", Date: today}); err != nil {
+		t.Fatal(err)
+	}
+
+	months, err := db.GetMonthlyCosts(1, CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 1 || months[0].Currency != "USD" || months[0].TotalCost != 6 {
+		t.Errorf("months = %+v, want one USD month of 6.00", months)
+	}
+}
+
+func TestPruneCostRecordsRemovesOnlyOlderRecords(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.SaveCostRecords([]CostRecord{
+		{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 1, Currency: "USD", Date: "2025-01-31"},
+		{SubscriptionID: "sub-1", ServiceName: "Functions", Cost: 2, Currency: "USD", Date: "2025-09-30"},
+		// The cutoff day itself is kept
+		{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 4, Currency: "USD", Date: "2025-10-01"},
+		{SubscriptionID: "sub-1", ServiceName: "Storage", Cost: 8, Currency: "USD", Date: "2026-10-01"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := db.PruneCostRecords("2025-10-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("removed %d records, want 2", removed)
+	}
+
+	remaining, err := db.GetCostRecords(CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 2 {
+		t.Fatalf("got %d remaining records, want 2", len(remaining))
+	}
+	for _, r := range remaining {
+		if r.Date < "2025-10-01" {
+			t.Errorf("record dated %s survived the prune", r.Date)
+		}
+	}
+
+	if removed, err := db.PruneCostRecords("2025-10-01"); err != nil || removed != 0 {
+		t.Errorf("second prune removed %d (err %v), want 0", removed, err)
+	}
+}
+
+func TestConcurrentSaveCostRecordAcrossConnections(t *testing.T) {
+	// Two handles on one file, as when the CLI and API server share it
+	path := filepath.Join(t.TempDir(), "azguard.db")
+	var handles []*DB
+	for i := 0; i < 2; i++ {
+		db, err := New(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		handles = append(handles, db)
+	}
+
+	var mode string
+	if err := handles[0].conn.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "wal" {
+		t.Errorf("journal mode = %q, want wal", mode)
+	}
+
+	const perHandle = 25
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*perHandle)
+	for h, db := range handles {
+		for i := 0; i < perHandle; i++ {
+			wg.Add(1)
+			go func(db *DB, service string) {
+				defer wg.Done()
+				errs <- db.SaveCostRecord(CostRecord{SubscriptionID: "sub-1", ServiceName: service, Cost: 1, Currency: "USD", Date: "2026-10-01"})
+			}(db, fmt.Sprintf("service-%d-%d", h, i))
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("SaveCostRecord: %v", err)
+		}
+	}
+
+	total, err := handles[1].GetTotalCost(CostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2*perHandle {
+		t.Errorf("total = %.2f, want %d.00", total, 2*perHandle)
+	}
+}